@@ -4,19 +4,82 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 type ServerConfigStruct struct {
-	Listen         string   `yaml:"listen" json:"listen"`
-	OllamaListen   string   `yaml:"ollama_listen" json:"ollama_listen"`
-	OllamaListens  []string `yaml:"ollama_listens" json:"ollama_listens"`
-	OllamaServices []string `yaml:"ollama_services" json:"ollama_services"`
-	NvidiaSmiPath  string   `yaml:"nvidia_smi_path" json:"nvidia_smi_path"`
-	GPUSampleDB    string   `yaml:"gpu_sample_db" json:"gpu_sample_db"`
+	Listen           string   `yaml:"listen" json:"listen"`
+	OllamaListen     string   `yaml:"ollama_listen" json:"ollama_listen"`
+	OllamaListens    []string `yaml:"ollama_listens" json:"ollama_listens"`
+	OllamaServices   []string `yaml:"ollama_services" json:"ollama_services"`
+	NvidiaSmiPath    string   `yaml:"nvidia_smi_path" json:"nvidia_smi_path"`
+	NvidiaSmiCommand string   `yaml:"nvidia_smi_command" json:"nvidia_smi_command"` // 完整调用命令（如 docker exec / ssh 前缀），设置后覆盖 NvidiaSmiPath
+	GPUCollector     string   `yaml:"gpu_collector" json:"gpu_collector"`
+	RocmSmiPath      string   `yaml:"rocm_smi_path" json:"rocm_smi_path"`
+	GPUSampleDB      string   `yaml:"gpu_sample_db" json:"gpu_sample_db"`
+
+	GPUIntervalMs       int `yaml:"gpu_interval_ms" json:"gpu_interval_ms"`             // GPU采样间隔，单位毫秒
+	OllamaIntervalMs    int `yaml:"ollama_interval_ms" json:"ollama_interval_ms"`       // Ollama状态采样间隔，单位毫秒
+	HostIntervalMs      int `yaml:"host_interval_ms" json:"host_interval_ms"`           // 主机指标采样间隔，单位毫秒
+	InventoryIntervalMs int `yaml:"inventory_interval_ms" json:"inventory_interval_ms"` // Ollama 模型清单（/api/tags）刷新间隔，单位毫秒
+	RealtimeIntervalMs  int `yaml:"realtime_interval_ms" json:"realtime_interval_ms"`   // 实时数据推送间隔，单位毫秒
+
+	OllamaTimeoutMs   int `yaml:"ollama_timeout_ms" json:"ollama_timeout_ms"`   // 单个 Ollama 实例的请求超时，单位毫秒
+	OllamaConcurrency int `yaml:"ollama_concurrency" json:"ollama_concurrency"` // 同时轮询的 Ollama 实例数量上限
+
+	OllamaFailThreshold int `yaml:"ollama_fail_threshold" json:"ollama_fail_threshold"` // 连续失败多少次判定为 down
+	OllamaFlapThreshold int `yaml:"ollama_flap_threshold" json:"ollama_flap_threshold"` // 5分钟内状态切换多少次判定为抖动
+	OllamaSlowMs        int `yaml:"ollama_slow_ms" json:"ollama_slow_ms"`               // 响应超过该耗时判定为 degraded，0 表示不检查
+
+	OllamaPins []OllamaPinConfig `yaml:"ollama_pins" json:"ollama_pins"` // 需要常驻的模型
+
+	EvictionEnabled     bool           `yaml:"eviction_enabled" json:"eviction_enabled"`           // 是否启用显存压力下的模型驱逐
+	EvictionDryRun      bool           `yaml:"eviction_dry_run" json:"eviction_dry_run"`           // 只记录将要驱逐的模型，不实际卸载
+	EvictionFreeMB      int            `yaml:"eviction_free_mb" json:"eviction_free_mb"`           // GPU空闲显存低于该值（MB）视为有压力
+	EvictionDurationSec int            `yaml:"eviction_duration_sec" json:"eviction_duration_sec"` // 压力持续多少秒后驱逐
+	EvictionStrategy    string         `yaml:"eviction_strategy" json:"eviction_strategy"`         // lru：最久未使用优先；priority：优先级最低的优先
+	EvictionPriorities  map[string]int `yaml:"eviction_priorities" json:"eviction_priorities"`     // 模型优先级，数值越大越不容易被驱逐，未配置为0
+
+	SupervisorEnabled            bool `yaml:"supervisor_enabled" json:"supervisor_enabled"`                             // 是否自动重启不健康的 Ollama 服务
+	SupervisorUnhealthySec       int  `yaml:"supervisor_unhealthy_sec" json:"supervisor_unhealthy_sec"`                 // 实例处于 down 状态持续多少秒后重启
+	SupervisorMaxRestartsPerHour int  `yaml:"supervisor_max_restarts_per_hour" json:"supervisor_max_restarts_per_hour"` // 每小时最多重启次数，超过后熔断
+
+	HungEnabled     bool   `yaml:"hung_enabled" json:"hung_enabled"`           // 是否检测推理卡死（GPU繁忙但 Ollama 无响应）
+	HungGPUUtil     int    `yaml:"hung_gpu_util" json:"hung_gpu_util"`         // GPU利用率不低于该值（百分比）视为繁忙
+	HungLatencyMs   int    `yaml:"hung_latency_ms" json:"hung_latency_ms"`     // 探测耗时不低于该值（毫秒）、超时或无法连接视为无响应
+	HungDurationSec int    `yaml:"hung_duration_sec" json:"hung_duration_sec"` // 持续多少秒后判定为卡死
+	HungRemediation string `yaml:"hung_remediation" json:"hung_remediation"`   // 卡死后的处理：none / unload / kill / restart
+
+	OllamaProbes    []OllamaProbeConfig `yaml:"ollama_probes" json:"ollama_probes"`         // 合成生成探测
+	ProbeIntervalMs int                 `yaml:"probe_interval_ms" json:"probe_interval_ms"` // 合成探测间隔，单位毫秒
+	ProbeTimeoutMs  int                 `yaml:"probe_timeout_ms" json:"probe_timeout_ms"`   // 单次合成探测的超时，单位毫秒
+
+	OllamaSnapshotIntervalMs int `yaml:"ollama_snapshot_interval_ms" json:"ollama_snapshot_interval_ms"` // 已加载模型快照的保存间隔，单位毫秒
+
+	HistoryRetentionSec int            `yaml:"history_retention_sec" json:"history_retention_sec"` // 历史数据默认保留时长，单位秒，0 表示永久保留
+	HistoryRetention    map[string]int `yaml:"history_retention" json:"history_retention"`         // 按数据系列（gpu、host、probe 等）单独设置的保留时长，单位秒
 }
 
+// OllamaPinConfig 模型常驻策略：指定模型必须保持加载在某个实例上
+type OllamaPinConfig struct {
+	Server    string `yaml:"server" json:"server"`         // Ollama服务地址，为空时使用第一个实例
+	Model     string `yaml:"model" json:"model"`           // 模型名称
+	KeepAlive string `yaml:"keep_alive" json:"keep_alive"` // 加载时使用的 keep_alive，默认 -1（永久）
+}
+
+// OllamaProbeConfig 合成探测：定期用一个很短的 prompt 请求指定模型，检查推理是否正常
+type OllamaProbeConfig struct {
+	Server     string `yaml:"server" json:"server"`           // Ollama服务地址，为空时探测所有实例
+	Model      string `yaml:"model" json:"model"`             // 模型名称
+	Prompt     string `yaml:"prompt" json:"prompt"`           // 探测使用的 prompt，默认 "Hi"
+	NumPredict int    `yaml:"num_predict" json:"num_predict"` // 最多生成的 token 数，默认 16
+}
+
+// DefaultGPUCollector 默认的GPU采集器
+const DefaultGPUCollector = "nvidia"
+
 func GetDefaulfAppDataPath() string {
 	dir, err := os.UserConfigDir()
 	if err != nil {
@@ -44,8 +107,67 @@ func GetDefaultServerConfig() ServerConfigStruct {
 		OllamaListens:  []string{"http://127.0.0.1:11434"},
 		OllamaServices: []string{"ollama"},
 		NvidiaSmiPath:  "/usr/bin/nvidia-smi",
+		GPUCollector:   DefaultGPUCollector,
+		RocmSmiPath:    "/opt/rocm/bin/rocm-smi",
 		GPUSampleDB:    GetDefaultDBConfigPath(),
+
+		GPUIntervalMs:       1000,
+		OllamaIntervalMs:    1000,
+		HostIntervalMs:      1000,
+		InventoryIntervalMs: 60000,
+		RealtimeIntervalMs:  1000,
+
+		OllamaTimeoutMs:   1000,
+		OllamaConcurrency: 8,
+
+		OllamaFailThreshold: 3,
+		OllamaFlapThreshold: 4,
+
+		EvictionDryRun:      true,
+		EvictionFreeMB:      1024,
+		EvictionDurationSec: 30,
+		EvictionStrategy:    "lru",
+
+		SupervisorUnhealthySec:       60,
+		SupervisorMaxRestartsPerHour: 3,
+
+		HungGPUUtil:     95,
+		HungLatencyMs:   5000,
+		HungDurationSec: 120,
+		HungRemediation: "none",
+
+		ProbeIntervalMs: 60000,
+		ProbeTimeoutMs:  30000,
+
+		OllamaSnapshotIntervalMs: 60000,
+
+		HistoryRetentionSec: 3600,
+		HistoryRetention: map[string]int{
+			"gpu_1m":      7 * 24 * 3600,
+			"gpu_1h":      90 * 24 * 3600,
+			"gpu_process": 7 * 24 * 3600,
+		},
+	}
+}
+
+// IntervalFromMs 将毫秒配置转换为时间间隔，未配置或过小时使用默认值
+func IntervalFromMs(ms int, fallback time.Duration) time.Duration {
+	if ms < 100 {
+		return fallback
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// RetentionOf 获取某个历史数据系列的保留时长，未单独配置时使用 history_retention_sec，返回0表示永久保留
+func (c *ServerConfigStruct) RetentionOf(series string) time.Duration {
+	sec, ok := c.HistoryRetention[series]
+	if !ok {
+		sec = c.HistoryRetentionSec
+	}
+	if sec <= 0 {
+		return 0
 	}
+	return time.Duration(sec) * time.Second
 }
 
 // ReadConfig 读取配置
@@ -16,12 +16,13 @@ func equalFieldName(fieldName, key string) bool {
 	return strings.EqualFold(normalizedFieldName, key)
 }
 
-// camelToSnake 将驼峰式变量名转换为下划线分隔形式
+// camelToSnake 将驼峰式变量名转换为下划线分隔形式，连续大写的缩写视为一个单词（GPUSampleDB -> gpu_sample_db）
 func camelToSnake(s string) string {
 	var result []rune
-	for i, r := range s {
+	runes := []rune(s)
+	for i, r := range runes {
 		if unicode.IsUpper(r) {
-			if i > 0 {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
 				result = append(result, '_')
 			}
 			result = append(result, unicode.ToLower(r))
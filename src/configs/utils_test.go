@@ -0,0 +1,62 @@
+package configs
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCamelToSnake(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Listen", "listen"},
+		{"OllamaListens", "ollama_listens"},
+		{"NvidiaSmiPath", "nvidia_smi_path"},
+		{"GPUSampleDB", "gpu_sample_db"},
+		{"GPUIntervalMs", "gpu_interval_ms"},
+		{"GPUCollector", "gpu_collector"},
+		{"HistoryRetentionSec", "history_retention_sec"},
+		{"SupervisorMaxRestartsPerHour", "supervisor_max_restarts_per_hour"},
+		{"HungGPUUtil", "hung_gpu_util"},
+		{"ID", "id"},
+	}
+	for _, tt := range tests {
+		if got := camelToSnake(tt.in); got != tt.want {
+			t.Errorf("camelToSnake(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// config set 通过字段名查找配置项，字段名转换结果必须与 yaml 标签一致
+func TestServerConfigKeysMatchYAMLTags(t *testing.T) {
+	typ := reflect.TypeOf(ServerConfigStruct{})
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if got := camelToSnake(field.Name); got != tag {
+			t.Errorf("field %s maps to %q, yaml tag is %q", field.Name, got, tag)
+		}
+	}
+}
+
+func TestSetConfigValue(t *testing.T) {
+	cfg := GetDefaultServerConfig()
+	for key, value := range map[string]string{
+		"gpu_sample_db":         "/tmp/samples",
+		"history_retention_sec": "86400",
+		"eviction_enabled":      "true",
+		"ollama_listens":        "http://a:11434,http://b:11434",
+	} {
+		if err := SetConfigValue(&cfg, key, value); err != nil {
+			t.Fatalf("SetConfigValue(%q): %v", key, err)
+		}
+	}
+	if cfg.GPUSampleDB != "/tmp/samples" || cfg.HistoryRetentionSec != 86400 || !cfg.EvictionEnabled || len(cfg.OllamaListens) != 2 {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+	if err := SetConfigValue(&cfg, "no_such_key", "1"); err == nil {
+		t.Error("expected error for unknown key")
+	}
+}
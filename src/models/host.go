@@ -0,0 +1,35 @@
+package models
+
+// HostMetrics 主机资源指标，用于观察 Ollama 显存不足时回落到 CPU/内存的情况
+type HostMetrics struct {
+	CPUUsed      float64 `json:"cpu_used"`      // CPU总利用率（百分比）
+	CPUIOWait    float64 `json:"cpu_iowait"`    // IO等待占比（百分比）
+	CPUCores     int     `json:"cpu_cores"`     // 逻辑核心数
+	Load1        float64 `json:"load1"`         // 1分钟平均负载
+	Load5        float64 `json:"load5"`         // 5分钟平均负载
+	Load15       float64 `json:"load15"`        // 15分钟平均负载
+	MemTotal     uint64  `json:"mem_total"`     // 内存总量，单位MB
+	MemUsed      uint64  `json:"mem_used"`      // 已用内存（总量减去可用），单位MB
+	MemAvailable uint64  `json:"mem_available"` // 可用内存，单位MB
+	SwapTotal    uint64  `json:"swap_total"`    // 交换分区总量，单位MB
+	SwapUsed     uint64  `json:"swap_used"`     // 已用交换分区，单位MB
+
+	Disks    []HostDiskStat `json:"disks"`
+	Networks []HostNetStat  `json:"networks"`
+
+	Timestamp   int64 `json:"timestamp"`    // 采样时间，单位秒
+	TimestampMs int64 `json:"timestamp_ms"` // 采样时间，单位毫秒
+}
+
+type HostDiskStat struct {
+	Name       string  `json:"name"`
+	ReadBytes  uint64  `json:"read_bytes"`  // 每秒读取字节数
+	WriteBytes uint64  `json:"write_bytes"` // 每秒写入字节数
+	IOUtil     float64 `json:"io_util"`     // 磁盘繁忙程度（百分比）
+}
+
+type HostNetStat struct {
+	Name    string `json:"name"`
+	RxBytes uint64 `json:"rx_bytes"` // 每秒接收字节数
+	TxBytes uint64 `json:"tx_bytes"` // 每秒发送字节数
+}
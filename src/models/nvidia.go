@@ -10,7 +10,22 @@ type GPUInfo struct {
 	GPUUsed     uint64  `json:"gpu_used"`    // GPU的利用率，表示当前GPU的使用百分比
 	Temperature uint64  `json:"temperature"` // GPU当前的温度
 	PowerUsage  float64 `json:"power_usage"` // GPU当前的当前功耗
-	PowerLimit  float64 `json:"power_limit"`   // GPU当前的功耗限制
+	PowerLimit  float64 `json:"power_limit"` // GPU当前的功耗限制
+
+	ClockSM          uint64   `json:"clock_sm"`                   // SM时钟频率，单位MHz
+	ClockMemory      uint64   `json:"clock_mem"`                  // 显存时钟频率，单位MHz
+	FanSpeed         uint64   `json:"fan_speed"`                  // 风扇转速百分比
+	PerformanceState string   `json:"pstate"`                     // 性能状态，如 P0、P8
+	PCIeGen          uint64   `json:"pcie_gen"`                   // 当前PCIe代数
+	PCIeWidth        uint64   `json:"pcie_width"`                 // 当前PCIe链路宽度
+	PCIeTxThroughput uint64   `json:"pcie_tx"`                    // PCIe发送吞吐，单位KB/s
+	PCIeRxThroughput uint64   `json:"pcie_rx"`                    // PCIe接收吞吐，单位KB/s
+	ThrottleReasons  []string `json:"throttle_reasons,omitempty"` // 当前生效的降频原因
+	ECCCorrected     uint64   `json:"ecc_corrected"`              // 可纠正ECC错误数（自驱动加载以来）
+	ECCUncorrected   uint64   `json:"ecc_uncorrected"`            // 不可纠正ECC错误数（自驱动加载以来）
+	EncoderUsed      uint64   `json:"encoder_used"`               // 编码器利用率
+	DecoderUsed      uint64   `json:"decoder_used"`               // 解码器利用率
+	MemoryBandwidth  uint64   `json:"mem_bandwidth_used"`         // 显存带宽利用率
 }
 
 type GPUProcess struct {
@@ -18,10 +33,88 @@ type GPUProcess struct {
 	PID        uint64 `json:"pid"`
 	Name       string `json:"name"`
 	MemoryUsed uint64 `json:"mem_used"`
+
+	// 以下信息来自 /proc/<pid>，进程不在本机（如远程调用nvidia-smi）时为空
+	PPID        uint64  `json:"ppid"`         // 父进程ID
+	User        string  `json:"user"`         // 进程所属用户
+	Cmdline     string  `json:"cmdline"`      // 完整命令行
+	StartTime   int64   `json:"start_time"`   // 进程启动时间，单位秒
+	CPUUsed     float64 `json:"cpu_used"`     // CPU利用率（百分比，多核可超过100）
+	RSS         uint64  `json:"rss"`          // 常驻内存，单位MB
+	Unit        string  `json:"unit"`         // 所属 systemd unit
+	ContainerId string  `json:"container_id"` // 所属容器ID（短ID）
+}
+
+// OllamaModelUsage Ollama已加载模型在某块GPU上的实际显存占用（由runner进程关联得到）
+type OllamaModelUsage struct {
+	Server     string `json:"server"`   // Ollama服务地址
+	Model      string `json:"model"`    // 模型名称
+	PID        uint64 `json:"pid"`      // runner进程ID
+	BusId      string `json:"bus_id"`   // PCI总线ID
+	MemoryUsed uint64 `json:"mem_used"` // 显存占用，单位MB
 }
 
 type NvidiaSMIResponse struct {
-	GPUInfo      []GPUInfo    `json:"gpu_info"`
-	GPUProcesses []GPUProcess `json:"gpu_processes"`
-	Timestamp    int64        `json:"timestamp"`
+	GPUInfo      []GPUInfo          `json:"gpu_info"`
+	GPUProcesses []GPUProcess       `json:"gpu_processes"`
+	OllamaModels []OllamaModelUsage `json:"ollama_models"`
+	Timestamp    int64              `json:"timestamp"`    // 采样时间，单位秒
+	TimestampMs  int64              `json:"timestamp_ms"` // 采样时间，单位毫秒
+}
+
+// GPUMetricStat 某个指标在聚合时间段内的统计值
+type GPUMetricStat struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+	Avg float64 `json:"avg"`
+	P95 float64 `json:"p95"`
+}
+
+// GPURollupStat 单个GPU在聚合时间段内各指标的统计值，键为 GPUInfo 中的 json 字段名
+type GPURollupStat struct {
+	BusId   string                   `json:"bus_id"`
+	Metrics map[string]GPUMetricStat `json:"metrics"`
+}
+
+// GPURollup GPU采样的聚合数据
+//
+// GPUInfo 为各指标的平均值，与原始采样格式兼容，详细统计值见 Stats
+type GPURollup struct {
+	Resolution  string          `json:"resolution"` // 聚合粒度：1m / 1h
+	Samples     int             `json:"samples"`    // 聚合的原始采样数
+	GPUInfo     []GPUInfo       `json:"gpu_info"`
+	Stats       []GPURollupStat `json:"stats"`
+	Timestamp   int64           `json:"timestamp"`    // 时间段起点，单位秒
+	TimestampMs int64           `json:"timestamp_ms"` // 时间段起点，单位毫秒
+}
+
+// GPU进程事件类型
+const (
+	GPUProcessStarted = "start"
+	GPUProcessStopped = "stop"
+)
+
+// GPUProcessRecord 一个GPU进程从出现到退出的记录
+type GPUProcessRecord struct {
+	PID          uint64   `json:"pid"`
+	Name         string   `json:"name"`
+	User         string   `json:"user"`
+	Cmdline      string   `json:"cmdline"`
+	Unit         string   `json:"unit"`
+	ContainerId  string   `json:"container_id"`
+	BusIds       []string `json:"bus_ids"`     // 使用过的GPU
+	StartTime    int64    `json:"start_time"`  // 进程启动时间，单位秒，无法获取或远程采集时为0
+	FirstSeen    int64    `json:"first_seen"`  // 首次出现在GPU进程列表的时间，单位秒
+	LastSeen     int64    `json:"last_seen"`   // 最后一次出现在GPU进程列表的时间，单位秒
+	MemoryPeak   uint64   `json:"mem_peak"`    // 显存占用峰值（所有GPU之和）
+	MemoryPeakAt int64    `json:"mem_peak_at"` // 达到峰值的时间，单位秒
+	Running      bool     `json:"running"`     // 是否仍在运行
+}
+
+// GPUProcessEvent GPU进程开始、结束事件
+type GPUProcessEvent struct {
+	GPUProcessRecord
+	Event       string `json:"event"`        // start / stop
+	Timestamp   int64  `json:"timestamp"`    // 单位秒
+	TimestampMs int64  `json:"timestamp_ms"` // 单位毫秒
 }
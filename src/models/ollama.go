@@ -0,0 +1,221 @@
+package models
+
+import "github.com/LanceLRQ/ollama-watchdog/ollama"
+
+// OllamaInstancePS 单个 Ollama 实例的 /api/ps 结果
+type OllamaInstancePS struct {
+	Server      string                `json:"server"`       // Ollama服务地址
+	ServiceName string                `json:"service_name"` // 对应的系统服务名称
+	Status      bool                  `json:"status"`       // 是否在线
+	Data        *ollama.PSResponse    `json:"data"`         // 离线时为空
+	Error       string                `json:"error"`        // 最近一次请求失败的原因
+	Unreachable bool                  `json:"unreachable"`  // 请求失败是否因为超时或无法连接，Ollama 返回的错误状态不算
+	LatencyMs   int64                 `json:"latency_ms"`   // 最近一次请求耗时，单位毫秒
+	LastSuccess int64                 `json:"last_success"` // 最近一次成功的时间，单位秒，从未成功为0
+	Health      *OllamaInstanceHealth `json:"health"`       // 健康状态
+	Pins        []OllamaPinState      `json:"pins"`         // 该实例上的常驻模型
+}
+
+// OllamaPSResponse 所有 Ollama 实例的 /api/ps 结果
+type OllamaPSResponse struct {
+	Status  bool               `json:"status"`
+	Data    []OllamaInstancePS `json:"data"`
+	Message string             `json:"message"`
+}
+
+// OllamaHealthState Ollama 实例健康状态
+type OllamaHealthState string
+
+const (
+	OllamaHealthUnknown  OllamaHealthState = "unknown"  // 尚未探测
+	OllamaHealthUp       OllamaHealthState = "up"       // 正常
+	OllamaHealthDegraded OllamaHealthState = "degraded" // 偶发失败、响应过慢或状态频繁抖动
+	OllamaHealthDown     OllamaHealthState = "down"     // 连续失败达到阈值
+)
+
+// OllamaInstanceHealth 单个 Ollama 实例的健康状态
+type OllamaInstanceHealth struct {
+	Server              string            `json:"server"`
+	State               OllamaHealthState `json:"state"`
+	ConsecutiveFailures int               `json:"consecutive_failures"` // 连续失败次数
+	Flapping            bool              `json:"flapping"`             // 近期状态切换过于频繁
+	LastHealthy         int64             `json:"last_healthy"`         // 最近一次成功的时间，单位秒
+	SinceHealthy        int64             `json:"since_healthy"`        // 距最近一次成功的秒数
+	LastChange          int64             `json:"last_change"`          // 最近一次状态变化的时间，单位秒
+	Version             string            `json:"version"`              // /api/version 返回的版本
+	LastError           string            `json:"last_error"`
+}
+
+// OllamaHealthEvent 实例健康状态变化事件
+type OllamaHealthEvent struct {
+	Server    string            `json:"server"`
+	From      OllamaHealthState `json:"from"`
+	To        OllamaHealthState `json:"to"`
+	Reason    string            `json:"reason"`
+	Timestamp int64             `json:"timestamp"`
+}
+
+// OllamaInventoryCopy 某个实例上的模型副本
+type OllamaInventoryCopy struct {
+	Server     string `json:"server"`
+	Digest     string `json:"digest"`
+	Size       int64  `json:"size"`
+	ModifiedAt int64  `json:"modified_at"` // 单位秒
+}
+
+// OllamaInventoryModel 按名称汇总的模型信息
+type OllamaInventoryModel struct {
+	Name              string                `json:"name"`
+	Size              int64                 `json:"size"`
+	Digest            string                `json:"digest"`
+	Family            string                `json:"family"`
+	ParameterSize     string                `json:"parameter_size"`
+	QuantizationLevel string                `json:"quantization_level"`
+	Format            string                `json:"format"`
+	Instances         []OllamaInventoryCopy `json:"instances"` // 拥有该模型的实例
+	Missing           []string              `json:"missing"`   // 在线但缺少该模型的实例
+	Drift             bool                  `json:"drift"`     // 各实例上的摘要不一致
+}
+
+// OllamaInventoryInstance 实例的清单拉取状态
+type OllamaInventoryInstance struct {
+	Server     string `json:"server"`
+	Status     bool   `json:"status"`
+	Error      string `json:"error"`
+	ModelCount int    `json:"model_count"`
+}
+
+// OllamaInventoryResponse 所有实例的模型清单
+type OllamaInventoryResponse struct {
+	Models    []OllamaInventoryModel    `json:"models"`
+	Instances []OllamaInventoryInstance `json:"instances"`
+	UpdatedAt int64                     `json:"updated_at"` // 单位秒
+}
+
+// OllamaPullJob 模型拉取任务
+type OllamaPullJob struct {
+	ID         string `json:"id"`
+	Server     string `json:"server"`
+	Model      string `json:"model"`
+	Status     string `json:"status"`    // running / success / failed / cancelled
+	Message    string `json:"message"`   // Ollama 返回的最新进度描述
+	Digest     string `json:"digest"`    // 当前下载的层
+	Total      int64  `json:"total"`     // 当前层总字节数
+	Completed  int64  `json:"completed"` // 当前层已下载字节数
+	Error      string `json:"error"`
+	StartedAt  int64  `json:"started_at"`  // 单位秒
+	FinishedAt int64  `json:"finished_at"` // 单位秒，未结束为0
+}
+
+const (
+	OllamaUnloadSuccess     = "unloaded"    // 已卸载
+	OllamaUnloadNotLoaded   = "not_loaded"  // 模型未加载
+	OllamaUnloadUnreachable = "unreachable" // 实例无法连接
+	OllamaUnloadFailed      = "failed"      // 其他错误
+)
+
+// OllamaUnloadResult 卸载模型的结果
+type OllamaUnloadResult struct {
+	Server  string `json:"server"`
+	Model   string `json:"model"`
+	Result  string `json:"result"`
+	Message string `json:"message"`
+}
+
+// OllamaPinState 常驻模型的状态
+type OllamaPinState struct {
+	Model       string `json:"model"`
+	KeepAlive   string `json:"keep_alive"`
+	Loaded      bool   `json:"loaded"`       // 当前是否已加载
+	Loading     bool   `json:"loading"`      // 是否正在加载
+	LastAttempt int64  `json:"last_attempt"` // 最近一次加载的时间，单位秒
+	LastError   string `json:"last_error"`
+}
+
+// OllamaEvictionRecord 模型驱逐记录
+type OllamaEvictionRecord struct {
+	Timestamp int64  `json:"timestamp"` // 单位秒
+	Server    string `json:"server"`
+	Model     string `json:"model"`
+	BusId     string `json:"bus_id"`  // 显存不足的GPU
+	FreeMB    uint64 `json:"free_mb"` // 驱逐时的空闲显存
+	Reason    string `json:"reason"`
+	DryRun    bool   `json:"dry_run"`
+	Result    string `json:"result"` // 卸载结果，dry run 时为空
+}
+
+// OllamaRestartRecord Ollama 服务重启记录
+type OllamaRestartRecord struct {
+	Timestamp   int64  `json:"timestamp"` // 单位秒
+	Server      string `json:"server"`
+	ServiceName string `json:"service_name"`
+	Reason      string `json:"reason"`
+	Result      string `json:"result"` // success / failed / skipped
+	Error       string `json:"error"`
+}
+
+// OllamaHungState 实例推理卡死检测状态
+type OllamaHungState struct {
+	Server          string   `json:"server"`
+	Hung            bool     `json:"hung"`             // 是否判定为卡死
+	MismatchSince   int64    `json:"mismatch_since"`   // GPU繁忙且无响应的开始时间，单位秒，未出现为0
+	BusIds          []string `json:"bus_ids"`          // 该实例使用的GPU
+	GPUUsed         uint64   `json:"gpu_used"`         // 这些GPU的最高利用率
+	LatencyMs       int64    `json:"latency_ms"`       // 最近一次探测耗时
+	LastError       string   `json:"last_error"`       // 最近一次探测错误
+	LastRemediation int64    `json:"last_remediation"` // 最近一次处理的时间，单位秒
+	Remediation     string   `json:"remediation"`      // 最近一次处理的方式与结果
+}
+
+// OllamaProbeResult 一次合成生成探测的结果
+type OllamaProbeResult struct {
+	Server          string  `json:"server"`
+	Model           string  `json:"model"`
+	Success         bool    `json:"success"`
+	Error           string  `json:"error"`
+	Unreachable     bool    `json:"unreachable"`       // 请求失败是否因为超时或无法连接，Ollama 返回的错误状态不算
+	TTFTMs          int64   `json:"ttft_ms"`           // 首个 token 的耗时，单位毫秒
+	LoadDurationMs  int64   `json:"load_duration_ms"`  // 模型加载耗时，单位毫秒
+	TotalDurationMs int64   `json:"total_duration_ms"` // 请求总耗时，单位毫秒
+	PromptEvalCount int     `json:"prompt_eval_count"`
+	EvalCount       int     `json:"eval_count"`
+	TokensPerSec    float64 `json:"tokens_per_sec"` // 生成速度，eval_count / eval_duration
+}
+
+// OllamaProbeResponse 一轮合成探测的结果
+type OllamaProbeResponse struct {
+	Results     []OllamaProbeResult `json:"results"`
+	Timestamp   int64               `json:"timestamp"`    // 单位秒
+	TimestampMs int64               `json:"timestamp_ms"` // 单位毫秒
+}
+
+// Ollama 模型加载事件类型
+const (
+	OllamaModelLoaded   = "load"
+	OllamaModelUnloaded = "unload"
+)
+
+// OllamaLoadedModel 某个实例上已加载的模型
+type OllamaLoadedModel struct {
+	Server    string `json:"server"`
+	Model     string `json:"model"`
+	Size      int64  `json:"size"`       // 模型占用的总内存，单位字节
+	SizeVRAM  int64  `json:"size_vram"`  // 模型占用的显存，单位字节
+	ExpiresAt int64  `json:"expires_at"` // 预计卸载时间，单位秒
+}
+
+// OllamaModelEvent 模型加载、卸载事件
+type OllamaModelEvent struct {
+	OllamaLoadedModel
+	Event       string `json:"event"`        // load / unload
+	Timestamp   int64  `json:"timestamp"`    // 单位秒
+	TimestampMs int64  `json:"timestamp_ms"` // 单位毫秒
+}
+
+// OllamaPSSnapshot 某一时刻所有实例已加载模型的快照
+type OllamaPSSnapshot struct {
+	Models      []OllamaLoadedModel `json:"models"`
+	Unreachable []string            `json:"unreachable"`  // 请求失败的实例，其模型未计入快照
+	Timestamp   int64               `json:"timestamp"`    // 单位秒
+	TimestampMs int64               `json:"timestamp_ms"` // 单位毫秒
+}
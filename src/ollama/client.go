@@ -0,0 +1,189 @@
+package ollama
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Client Ollama HTTP API 客户端，超时由调用方通过 context 控制
+type Client struct {
+	Host       string
+	HTTPClient *http.Client
+}
+
+func NewClient(host string) *Client {
+	return &Client{
+		Host:       strings.TrimRight(host, "/"),
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// PS 获取已加载的模型
+func (c *Client) PS(ctx context.Context) (*PSResponse, error) {
+	var resp PSResponse
+	if err := c.do(ctx, http.MethodGet, "/api/ps", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Tags 获取本地模型列表
+func (c *Client) Tags(ctx context.Context) (*ListResponse, error) {
+	var resp ListResponse
+	if err := c.do(ctx, http.MethodGet, "/api/tags", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Show 获取模型详情
+func (c *Client) Show(ctx context.Context, req *ShowRequest) (*ShowResponse, error) {
+	var resp ShowResponse
+	if err := c.do(ctx, http.MethodPost, "/api/show", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Version 获取 Ollama 版本
+func (c *Client) Version(ctx context.Context) (*VersionResponse, error) {
+	var resp VersionResponse
+	if err := c.do(ctx, http.MethodGet, "/api/version", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Generate 生成文本，流式返回时每收到一行调用一次 fn
+func (c *Client) Generate(ctx context.Context, req *GenerateRequest, fn func(GenerateResponse) error) error {
+	return c.stream(ctx, http.MethodPost, "/api/generate", req, func(line []byte) error {
+		var resp GenerateResponse
+		if err := json.Unmarshal(line, &resp); err != nil {
+			return err
+		}
+		return fn(resp)
+	})
+}
+
+// Pull 拉取模型，每收到一条进度调用一次 fn
+func (c *Client) Pull(ctx context.Context, req *PullRequest, fn func(ProgressResponse) error) error {
+	return c.stream(ctx, http.MethodPost, "/api/pull", req, func(line []byte) error {
+		var resp ProgressResponse
+		if err := json.Unmarshal(line, &resp); err != nil {
+			return err
+		}
+		return fn(resp)
+	})
+}
+
+// Delete 删除模型
+func (c *Client) Delete(ctx context.Context, req *DeleteRequest) error {
+	return c.do(ctx, http.MethodDelete, "/api/delete", req, nil)
+}
+
+// Copy 复制模型
+func (c *Client) Copy(ctx context.Context, req *CopyRequest) error {
+	return c.do(ctx, http.MethodPost, "/api/copy", req, nil)
+}
+
+func (c *Client) newRequest(ctx context.Context, method string, path string, body any) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.Host+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	return req, nil
+}
+
+func (c *Client) send(ctx context.Context, method string, path string, body any) (*http.Response, error) {
+	req, err := c.newRequest(ctx, method, path, body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, &UnreachableError{Host: c.Host, Err: err}
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+		return nil, readStatusError(resp)
+	}
+	return resp, nil
+}
+
+func (c *Client) do(ctx context.Context, method string, path string, body any, out any) error {
+	resp, err := c.send(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("ollama: invalid response from %s: %w", path, err)
+	}
+	return nil
+}
+
+// stream 逐行处理 NDJSON 响应，行内出现 error 字段时返回错误
+func (c *Client) stream(ctx context.Context, method string, path string, body any, fn func([]byte) error) error {
+	resp, err := c.send(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 8*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(line, &errResp) == nil && errResp.Error != "" {
+			return &StatusError{StatusCode: resp.StatusCode, Message: errResp.Error}
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return &UnreachableError{Host: c.Host, Err: err}
+	}
+	return nil
+}
+
+func readStatusError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var errResp struct {
+		Error string `json:"error"`
+	}
+	message := strings.TrimSpace(string(data))
+	if json.Unmarshal(data, &errResp) == nil && errResp.Error != "" {
+		message = errResp.Error
+	}
+	return &StatusError{StatusCode: resp.StatusCode, Message: message}
+}
@@ -0,0 +1,239 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestServer 模拟 Ollama，按路径返回固定内容，并检查请求方法
+func newTestServer(t *testing.T, routes map[string]http.HandlerFunc) *Client {
+	t.Helper()
+	mux := http.NewServeMux()
+	for pattern, handler := range routes {
+		mux.HandleFunc(pattern, handler)
+	}
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	// 末尾的斜杠应被去掉
+	return NewClient(srv.URL + "/")
+}
+
+func writeLines(w http.ResponseWriter, lines ...string) {
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+		w.(http.Flusher).Flush()
+	}
+}
+
+func TestClientPS(t *testing.T) {
+	client := newTestServer(t, map[string]http.HandlerFunc{
+		"GET /api/ps": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"models":[{"name":"llama3:8b","model":"llama3:8b","size":6654289920,"digest":"365c0bd3c000","details":{"family":"llama","parameter_size":"8.0B","quantization_level":"Q4_0"},"expires_at":"2024-06-04T14:38:31.83753-07:00","size_vram":5137025024}]}`)
+		},
+	})
+	resp, err := client.PS(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Models) != 1 {
+		t.Fatalf("got %d models", len(resp.Models))
+	}
+	m := resp.Models[0]
+	if m.Name != "llama3:8b" || m.SizeVRAM != 5137025024 || m.Details.QuantizationLevel != "Q4_0" || m.ExpiresAt.IsZero() {
+		t.Errorf("unexpected model: %+v", m)
+	}
+}
+
+func TestClientTags(t *testing.T) {
+	client := newTestServer(t, map[string]http.HandlerFunc{
+		"GET /api/tags": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"models":[{"name":"qwen2.5:0.5b","model":"qwen2.5:0.5b","modified_at":"2024-09-20T10:00:00Z","size":397821319,"digest":"a8b0c5157701","details":{"format":"gguf","family":"qwen2","parameter_size":"494.03M","quantization_level":"Q4_K_M"}}]}`)
+		},
+	})
+	resp, err := client.Tags(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Models) != 1 || resp.Models[0].Size != 397821319 || resp.Models[0].Details.Family != "qwen2" {
+		t.Errorf("unexpected tags: %+v", resp)
+	}
+}
+
+func TestClientShow(t *testing.T) {
+	client := newTestServer(t, map[string]http.HandlerFunc{
+		"POST /api/show": func(w http.ResponseWriter, r *http.Request) {
+			var req ShowRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Model != "llama3:8b" {
+				http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"modelfile":"FROM /blobs/sha256-abc\n","details":{"family":"llama"},"capabilities":["completion"]}`)
+		},
+	})
+	resp, err := client.Show(context.Background(), &ShowRequest{Model: "llama3:8b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Modelfile != "FROM /blobs/sha256-abc\n" || resp.Details.Family != "llama" {
+		t.Errorf("unexpected show: %+v", resp)
+	}
+}
+
+func TestClientVersion(t *testing.T) {
+	client := newTestServer(t, map[string]http.HandlerFunc{
+		"GET /api/version": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"version":"0.3.12"}`)
+		},
+	})
+	resp, err := client.Version(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Version != "0.3.12" {
+		t.Errorf("version = %q", resp.Version)
+	}
+}
+
+func TestClientGenerateStream(t *testing.T) {
+	client := newTestServer(t, map[string]http.HandlerFunc{
+		"POST /api/generate": func(w http.ResponseWriter, r *http.Request) {
+			var req GenerateRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.Stream == nil || !*req.Stream || req.KeepAlive != float64(0) {
+				http.Error(w, `{"error":"unexpected request"}`, http.StatusBadRequest)
+				return
+			}
+			writeLines(w,
+				`{"model":"m","response":"Hel","done":false}`,
+				``,
+				`{"model":"m","response":"lo","done":false}`,
+				`{"model":"m","response":"","done":true,"done_reason":"stop","total_duration":600000000,"load_duration":100000000,"eval_count":2,"eval_duration":400000000}`,
+			)
+		},
+	})
+	stream := true
+	var text string
+	var final GenerateResponse
+	err := client.Generate(context.Background(), &GenerateRequest{Model: "m", Prompt: "hi", Stream: &stream, KeepAlive: 0}, func(resp GenerateResponse) error {
+		text += resp.Response
+		if resp.Done {
+			final = resp
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "Hello" || !final.Done || final.EvalCount != 2 || final.LoadDuration != 100000000 || final.DoneReason != "stop" {
+		t.Errorf("text %q, final %+v", text, final)
+	}
+}
+
+func TestClientPullStream(t *testing.T) {
+	client := newTestServer(t, map[string]http.HandlerFunc{
+		"POST /api/pull": func(w http.ResponseWriter, r *http.Request) {
+			writeLines(w,
+				`{"status":"pulling manifest"}`,
+				`{"status":"pulling a8b0c5157701","digest":"sha256:a8b0c5157701","total":397821319,"completed":1000}`,
+				`{"status":"success"}`,
+			)
+		},
+	})
+	var progress []ProgressResponse
+	err := client.Pull(context.Background(), &PullRequest{Model: "qwen2.5:0.5b"}, func(resp ProgressResponse) error {
+		progress = append(progress, resp)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(progress) != 3 || progress[1].Total != 397821319 || progress[2].Status != "success" {
+		t.Errorf("unexpected progress: %+v", progress)
+	}
+}
+
+func TestClientStreamError(t *testing.T) {
+	// 拉取过程中出错时 Ollama 仍返回 200，错误在某一行的 error 字段中
+	client := newTestServer(t, map[string]http.HandlerFunc{
+		"POST /api/pull": func(w http.ResponseWriter, r *http.Request) {
+			writeLines(w, `{"status":"pulling manifest"}`, `{"error":"pull model manifest: file does not exist"}`)
+		},
+	})
+	err := client.Pull(context.Background(), &PullRequest{Model: "nope"}, func(ProgressResponse) error { return nil })
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.Message != "pull model manifest: file does not exist" {
+		t.Errorf("err = %v, want StatusError", err)
+	}
+}
+
+func TestClientStatusError(t *testing.T) {
+	client := newTestServer(t, map[string]http.HandlerFunc{
+		"POST /api/show": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":"model 'nope' not found"}`)
+		},
+		"GET /api/ps": func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "upstream failure", http.StatusInternalServerError)
+		},
+	})
+
+	_, err := client.Show(context.Background(), &ShowRequest{Model: "nope"})
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound || statusErr.Message != "model 'nope' not found" {
+		t.Errorf("err = %v, want 404 StatusError", err)
+	}
+	if !IsNotFound(err) || IsUnreachable(err) {
+		t.Errorf("IsNotFound/IsUnreachable misclassified %v", err)
+	}
+
+	_, err = client.PS(context.Background())
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusInternalServerError || statusErr.Message != "upstream failure" {
+		t.Errorf("err = %v, want 500 StatusError", err)
+	}
+	if IsNotFound(err) {
+		t.Errorf("500 classified as not found")
+	}
+}
+
+func TestClientUnreachable(t *testing.T) {
+	// 占用一个端口后立即关闭，保证连接被拒绝
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	_, err = NewClient("http://" + addr).Version(context.Background())
+	var unreachableErr *UnreachableError
+	if !errors.As(err, &unreachableErr) || !IsUnreachable(err) {
+		t.Fatalf("err = %v, want UnreachableError", err)
+	}
+	if unreachableErr.Host != "http://"+addr {
+		t.Errorf("host = %q", unreachableErr.Host)
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	client := newTestServer(t, map[string]http.HandlerFunc{
+		"GET /api/ps": func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := client.PS(ctx)
+	if !IsUnreachable(err) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want unreachable wrapping deadline exceeded", err)
+	}
+}
@@ -0,0 +1,46 @@
+package ollama
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// StatusError Ollama 返回了非 2xx 状态码
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("ollama: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("ollama: %d %s", e.StatusCode, e.Message)
+}
+
+// UnreachableError 无法连接 Ollama 实例（连接失败、超时等）
+type UnreachableError struct {
+	Host string
+	Err  error
+}
+
+func (e *UnreachableError) Error() string {
+	return fmt.Sprintf("ollama: instance %s unreachable: %v", e.Host, e.Err)
+}
+
+func (e *UnreachableError) Unwrap() error {
+	return e.Err
+}
+
+// IsNotFound 判断是否为模型不存在
+func IsNotFound(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound
+}
+
+// IsUnreachable 判断是否为实例无法连接
+func IsUnreachable(err error) bool {
+	var unreachableErr *UnreachableError
+	return errors.As(err, &unreachableErr)
+}
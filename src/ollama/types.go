@@ -0,0 +1,129 @@
+package ollama
+
+import "time"
+
+// ModelDetails 模型的基本信息
+type ModelDetails struct {
+	ParentModel       string   `json:"parent_model"`
+	Format            string   `json:"format"`
+	Family            string   `json:"family"`
+	Families          []string `json:"families"`
+	ParameterSize     string   `json:"parameter_size"`
+	QuantizationLevel string   `json:"quantization_level"`
+}
+
+// ProcessModel /api/ps 中已加载的模型
+type ProcessModel struct {
+	Name          string       `json:"name"`
+	Model         string       `json:"model"`
+	Size          int64        `json:"size"`
+	Digest        string       `json:"digest"`
+	Details       ModelDetails `json:"details"`
+	ExpiresAt     time.Time    `json:"expires_at"`
+	SizeVRAM      int64        `json:"size_vram"`
+	ContextLength int          `json:"context_length,omitempty"`
+}
+
+// PSResponse /api/ps 的返回
+type PSResponse struct {
+	Models []ProcessModel `json:"models"`
+}
+
+// ListModel /api/tags 中的本地模型
+type ListModel struct {
+	Name       string       `json:"name"`
+	Model      string       `json:"model"`
+	ModifiedAt time.Time    `json:"modified_at"`
+	Size       int64        `json:"size"`
+	Digest     string       `json:"digest"`
+	Details    ModelDetails `json:"details"`
+}
+
+// ListResponse /api/tags 的返回
+type ListResponse struct {
+	Models []ListModel `json:"models"`
+}
+
+// ShowRequest /api/show 的请求
+type ShowRequest struct {
+	Model   string `json:"model"`
+	Verbose bool   `json:"verbose,omitempty"`
+}
+
+// ShowResponse /api/show 的返回
+type ShowResponse struct {
+	License      string         `json:"license,omitempty"`
+	Modelfile    string         `json:"modelfile,omitempty"`
+	Parameters   string         `json:"parameters,omitempty"`
+	Template     string         `json:"template,omitempty"`
+	System       string         `json:"system,omitempty"`
+	Details      ModelDetails   `json:"details"`
+	ModelInfo    map[string]any `json:"model_info,omitempty"`
+	Capabilities []string       `json:"capabilities,omitempty"`
+	ModifiedAt   time.Time      `json:"modified_at"`
+}
+
+// VersionResponse /api/version 的返回
+type VersionResponse struct {
+	Version string `json:"version"`
+}
+
+// GenerateRequest /api/generate 的请求
+//
+// KeepAlive 可以是秒数（0 表示立即卸载，-1 表示常驻）或时长字符串（如 "5m"），为 nil 时使用服务端默认值
+type GenerateRequest struct {
+	Model     string         `json:"model"`
+	Prompt    string         `json:"prompt"`
+	System    string         `json:"system,omitempty"`
+	Template  string         `json:"template,omitempty"`
+	Context   []int          `json:"context,omitempty"`
+	Stream    *bool          `json:"stream,omitempty"`
+	Raw       bool           `json:"raw,omitempty"`
+	Format    string         `json:"format,omitempty"`
+	KeepAlive any            `json:"keep_alive,omitempty"`
+	Options   map[string]any `json:"options,omitempty"`
+}
+
+// GenerateResponse /api/generate 的返回，流式输出时每行一个
+//
+// 各耗时字段单位为纳秒，仅在 Done 为 true 时有值
+type GenerateResponse struct {
+	Model              string    `json:"model"`
+	CreatedAt          time.Time `json:"created_at"`
+	Response           string    `json:"response"`
+	Done               bool      `json:"done"`
+	DoneReason         string    `json:"done_reason,omitempty"`
+	Context            []int     `json:"context,omitempty"`
+	TotalDuration      int64     `json:"total_duration,omitempty"`
+	LoadDuration       int64     `json:"load_duration,omitempty"`
+	PromptEvalCount    int       `json:"prompt_eval_count,omitempty"`
+	PromptEvalDuration int64     `json:"prompt_eval_duration,omitempty"`
+	EvalCount          int       `json:"eval_count,omitempty"`
+	EvalDuration       int64     `json:"eval_duration,omitempty"`
+}
+
+// PullRequest /api/pull 的请求
+type PullRequest struct {
+	Model    string `json:"model"`
+	Insecure bool   `json:"insecure,omitempty"`
+	Stream   *bool  `json:"stream,omitempty"`
+}
+
+// ProgressResponse /api/pull 的流式进度
+type ProgressResponse struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+}
+
+// DeleteRequest /api/delete 的请求
+type DeleteRequest struct {
+	Model string `json:"model"`
+}
+
+// CopyRequest /api/copy 的请求
+type CopyRequest struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+}
@@ -3,6 +3,7 @@ package server
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
 	"time"
 
@@ -10,7 +11,6 @@ import (
 	"github.com/LanceLRQ/ollama-watchdog/models"
 	"github.com/LanceLRQ/ollama-watchdog/services"
 	"github.com/LanceLRQ/ollama-watchdog/utils"
-	"github.com/dgraph-io/badger/v4"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/filesystem"
@@ -20,21 +20,75 @@ import (
 
 func StartHttpServer(cfg *configs.ServerConfigStruct) error {
 	var nvidiaResp models.NvidiaSMIResponse
-	var ollamaPSResp fiber.Map
+	var ollamaPSResp models.OllamaPSResponse
+	var hostResp models.HostMetrics
+	var probeResp models.OllamaProbeResponse
 
 	GPUSampleDB, err := utils.OpenBadgerDB(cfg.GPUSampleDB)
 	if err != nil {
 		return fmt.Errorf("failed to open badger db: %w", err)
 	}
 	defer GPUSampleDB.Close()
+	go services.HistoryGCWatcher(GPUSampleDB, 10*time.Minute)
+	go services.GPURollupWatcher(GPUSampleDB, cfg)
 
-	go services.NvidiaSMIWatcher(func(response models.NvidiaSMIResponse) {
+	gpuCollector, err := services.NewGPUCollector(cfg)
+	if err != nil {
+		return err
+	}
+
+	attributor := services.NewOllamaModelAttributor(cfg)
+	gpuProcesses := services.NewGPUProcessTracker(GPUSampleDB, cfg)
+	ollamaPinner := services.NewOllamaPinner(cfg)
+	ollamaEvictor := services.NewOllamaEvictor(cfg, ollamaPinner)
+	ollamaHealth := services.NewOllamaHealthTracker(cfg)
+	ollamaHealth.Subscribe(func(event models.OllamaHealthEvent) {
+		fmt.Printf("Ollama instance %s: %s -> %s (%s)\n", event.Server, event.From, event.To, event.Reason)
+	})
+	ollamaSupervisor := services.NewOllamaSupervisor(cfg, ollamaHealth)
+	ollamaHung := services.NewOllamaHungDetector(cfg)
+	ollamaHistory := services.NewOllamaHistoryRecorder(GPUSampleDB, cfg)
+
+	// 进程不在本机时不能从 /proc 补充详情，否则会读到同 PID 的其他进程
+	var processEnricher *services.ProcessEnricher
+	if services.IsLocalGPUCollector(cfg) {
+		processEnricher = services.NewProcessEnricher()
+	}
+	go services.GPUWatcher(gpuCollector, processEnricher, configs.IntervalFromMs(cfg.GPUIntervalMs, time.Second), func(response models.NvidiaSMIResponse) {
+		response.OllamaModels = attributor.Attribute(response.GPUProcesses)
+		ollamaEvictor.Check(response)
+		ollamaHung.Check(response)
+		gpuProcesses.Update(response)
 		nvidiaResp = response
-		services.SaveSampleToDB(GPUSampleDB, response)
+		services.SaveSampleToDB(GPUSampleDB, response, cfg.RetentionOf("gpu"))
 	})
-	go services.OllamaPSWatcher(cfg, func(response fiber.Map) {
+
+	go services.OllamaPSWatcher(cfg, ollamaHealth, func(response models.OllamaPSResponse) {
+		attributor.UpdateOllamaPS(response)
+		ollamaPinner.Enforce(response)
+		ollamaEvictor.UpdateOllamaPS(response)
+		ollamaSupervisor.Check(response)
+		ollamaHung.UpdateOllamaPS(response)
+		ollamaHistory.Record(response)
+		ollamaPinner.Annotate(&response)
 		ollamaPSResp = response
 	})
+	go services.OllamaProbeWatcher(cfg, func(response models.OllamaProbeResponse) {
+		for _, result := range response.Results {
+			if !result.Success {
+				fmt.Printf("Ollama probe %s on %s failed: %s\n", result.Model, result.Server, result.Error)
+			}
+		}
+		ollamaHung.UpdateProbes(response)
+		probeResp = response
+		services.SaveProbeSampleToDB(GPUSampleDB, response, cfg.RetentionOf("probe"))
+	})
+	ollamaInventory := services.NewOllamaInventory(cfg)
+	go services.OllamaInventoryWatcher(ollamaInventory, configs.IntervalFromMs(cfg.InventoryIntervalMs, time.Minute))
+	go services.HostWatcher(services.NewHostCollector(), configs.IntervalFromMs(cfg.HostIntervalMs, time.Second), func(response models.HostMetrics) {
+		hostResp = response
+		services.SaveHostSampleToDB(GPUSampleDB, response, cfg.RetentionOf("host"))
+	})
 
 	app := fiber.New()
 	// 使用 CORS 中间件
@@ -46,13 +100,15 @@ func StartHttpServer(cfg *configs.ServerConfigStruct) error {
 
 	// WebSocket服务
 	app.Get("/api/realtime", websocket.New(func(c *websocket.Conn) {
-		ticker := time.NewTicker(1 * time.Second)
+		ticker := time.NewTicker(configs.IntervalFromMs(cfg.RealtimeIntervalMs, time.Second))
 		defer ticker.Stop()
 
 		for range ticker.C {
 			jsonData, err := json.Marshal(fiber.Map{
 				"nvidia": nvidiaResp,
 				"ollama": ollamaPSResp,
+				"host":   hostResp,
+				"probe":  probeResp,
 			})
 			if err != nil {
 				fmt.Println("JSON marshal error:", err)
@@ -68,29 +124,45 @@ func StartHttpServer(cfg *configs.ServerConfigStruct) error {
 
 	app.Get("/api/nvidia/history", func(c *fiber.Ctx) error {
 		r := c.QueryInt("range", 120)
+		// 未指定 resolution 时根据范围自动选择原始采样或聚合数据
+		resolution := c.Query("resolution", services.GPUHistoryResolution(cfg, r))
 
-		start := time.Now().Add(time.Duration(-r) * time.Second).Unix()
-		responstList := make([]models.NvidiaSMIResponse, 0)
-
-		err = GPUSampleDB.View(func(txn *badger.Txn) error {
-			opts := badger.DefaultIteratorOptions
-			it := txn.NewIterator(opts)
-			defer it.Close()
-			for it.Seek(fmt.Appendf(nil, "gpu:%d", start)); it.Valid(); it.Next() {
-				item := it.Item()
-				v, err := item.ValueCopy(nil)
-				if err != nil {
-					return err
-				}
-				var nvidiaRespLog models.NvidiaSMIResponse
-				err = json.Unmarshal(v, &nvidiaRespLog)
-				if err != nil {
-					return err
-				}
-				responstList = append(responstList, nvidiaRespLog)
-			}
-			return nil
+		start := time.Now().Add(time.Duration(-r) * time.Second).UnixMilli()
+		var responstList any
+		var err error
+		switch resolution {
+		case services.GPUHistoryRaw:
+			responstList, err = services.LoadSamples[models.NvidiaSMIResponse](GPUSampleDB, "gpu", start)
+		case services.GPUHistoryMinute, services.GPUHistoryHour:
+			responstList, err = services.LoadSamples[models.GPURollup](GPUSampleDB, "gpu_"+resolution, start)
+		default:
+			err = fmt.Errorf("unsupported resolution: %s", resolution)
+		}
+		if err != nil {
+			return c.JSON(fiber.Map{
+				"status":  false,
+				"message": err.Error(),
+			})
+		}
+		return c.JSON(fiber.Map{
+			"status":     true,
+			"resolution": resolution,
+			"data":       responstList,
 		})
+	})
+	app.Get("/api/nvidia/processes/history", func(c *fiber.Ctx) error {
+		r := c.QueryInt("range", 3600)
+
+		// start / end 为毫秒时间戳，未指定 start 时按 range 从当前时间往前推
+		start := time.Now().Add(time.Duration(-r) * time.Second).UnixMilli()
+		if v := c.QueryInt("start", 0); v > 0 {
+			start = int64(v)
+		}
+		end := int64(math.MaxInt64)
+		if v := c.QueryInt("end", 0); v > 0 {
+			end = int64(v)
+		}
+		responstList, err := gpuProcesses.History(start, end, uint64(c.QueryInt("pid", 0)), c.Query("name"))
 		if err != nil {
 			return c.JSON(fiber.Map{
 				"status":  false,
@@ -109,6 +181,29 @@ func StartHttpServer(cfg *configs.ServerConfigStruct) error {
 		})
 	})
 
+	app.Get("/api/host/history", func(c *fiber.Ctx) error {
+		r := c.QueryInt("range", 120)
+
+		start := time.Now().Add(time.Duration(-r) * time.Second).UnixMilli()
+		responstList, err := services.LoadSamples[models.HostMetrics](GPUSampleDB, "host", start)
+		if err != nil {
+			return c.JSON(fiber.Map{
+				"status":  false,
+				"message": err.Error(),
+			})
+		}
+		return c.JSON(fiber.Map{
+			"status": true,
+			"data":   responstList,
+		})
+	})
+	app.Get("/api/host/now", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{
+			"status": true,
+			"data":   hostResp,
+		})
+	})
+
 	app.Post("/api/kill", func(c *fiber.Ctx) error {
 		data := new(struct {
 			Type   string `json:"type"`
@@ -121,11 +216,15 @@ func StartHttpServer(cfg *configs.ServerConfigStruct) error {
 		}
 		if data.Type == "ollama" {
 			if data.Name != "" {
-				err := utils.TerminateOllamaProcess(cfg, data.Name, data.Server)
+				server, err := services.ResolveOllamaServer(cfg, data.Server)
 				if err != nil {
-					return c.JSON(fiber.Map{"status": false, "message": "Failed to terminate process"})
+					return c.JSON(fiber.Map{"status": false, "message": err.Error()})
 				}
-				return c.JSON(fiber.Map{"status": true})
+				result := services.UnloadOllamaModel(server, data.Name, 30*time.Second)
+				if result.Result != models.OllamaUnloadSuccess {
+					return c.JSON(fiber.Map{"status": false, "message": result.Message, "data": result})
+				}
+				return c.JSON(fiber.Map{"status": true, "data": result})
 			}
 			return c.JSON(fiber.Map{"status": false, "message": "ollama model name is required"})
 		} else if data.Type == "process" {
@@ -160,6 +259,7 @@ func StartHttpServer(cfg *configs.ServerConfigStruct) error {
 			return err
 		}
 		err := utils.RestartServiceProcess(data.Type, data.ServiceName)
+		ollamaSupervisor.RecordManual(data.ServiceName, data.Type, err)
 		if err != nil {
 			return c.JSON(fiber.Map{"status": false, "message": err.Error()})
 		}
@@ -176,10 +276,193 @@ func StartHttpServer(cfg *configs.ServerConfigStruct) error {
 		return nil
 	})
 
+	// 返回轮询缓存的结果，健康状态只由轮询更新，不受请求频率影响
 	app.Get("/api/ollama/ps", func(c *fiber.Ctx) error {
-		result := services.GetOllamaPS(cfg)
-		// Remove Server header from response
-		return c.JSON(result)
+		return c.JSON(ollamaPSResp)
+	})
+
+	app.Get("/api/ollama/history", func(c *fiber.Ctx) error {
+		r := c.QueryInt("range", 3600)
+
+		start := time.Now().Add(time.Duration(-r) * time.Second).UnixMilli()
+		events, snapshots, err := services.LoadOllamaHistory(GPUSampleDB, start, c.Query("server"), c.Query("model"))
+		if err != nil {
+			return c.JSON(fiber.Map{
+				"status":  false,
+				"message": err.Error(),
+			})
+		}
+		return c.JSON(fiber.Map{
+			"status": true,
+			"data": fiber.Map{
+				"events":    events,
+				"snapshots": snapshots,
+			},
+		})
+	})
+
+	app.Get("/api/ollama/models", func(c *fiber.Ctx) error {
+		// refresh=1 时立即重新拉取
+		if c.QueryBool("refresh") {
+			return c.JSON(fiber.Map{
+				"status": true,
+				"data":   ollamaInventory.Refresh(),
+			})
+		}
+		return c.JSON(fiber.Map{
+			"status": true,
+			"data":   ollamaInventory.Get(),
+		})
+	})
+
+	ollamaPulls := services.NewOllamaPullManager(func(job models.OllamaPullJob) {
+		fmt.Printf("Ollama pull %s on %s: %s %s\n", job.Model, job.Server, job.Status, job.Error)
+		go ollamaInventory.Refresh()
+	})
+
+	app.Post("/api/ollama/models/pull", func(c *fiber.Ctx) error {
+		data := new(struct {
+			Server   string `json:"server"`
+			Model    string `json:"model"`
+			Insecure bool   `json:"insecure"`
+		})
+		if err := c.BodyParser(&data); err != nil {
+			return err
+		}
+		if data.Model == "" {
+			return c.JSON(fiber.Map{"status": false, "message": "ollama model name is required"})
+		}
+		server, err := services.ResolveOllamaServer(cfg, data.Server)
+		if err != nil {
+			return c.JSON(fiber.Map{"status": false, "message": err.Error()})
+		}
+		return c.JSON(fiber.Map{"status": true, "data": ollamaPulls.Start(server, data.Model, data.Insecure)})
+	})
+	app.Get("/api/ollama/models/pulls", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{"status": true, "data": ollamaPulls.List()})
+	})
+	app.Get("/api/ollama/models/pulls/:id", func(c *fiber.Ctx) error {
+		job, ok := ollamaPulls.Get(c.Params("id"))
+		if !ok {
+			return c.JSON(fiber.Map{"status": false, "message": "pull job not found"})
+		}
+		return c.JSON(fiber.Map{"status": true, "data": job})
+	})
+	app.Delete("/api/ollama/models/pulls/:id", func(c *fiber.Ctx) error {
+		if err := ollamaPulls.Cancel(c.Params("id")); err != nil {
+			return c.JSON(fiber.Map{"status": false, "message": err.Error()})
+		}
+		return c.JSON(fiber.Map{"status": true})
+	})
+	// 拉取进度推送，连接断开不影响任务执行
+	app.Get("/api/ollama/models/pulls/:id/progress", websocket.New(func(c *websocket.Conn) {
+		progress, unsubscribe, err := ollamaPulls.Subscribe(c.Params("id"))
+		if err != nil {
+			c.WriteJSON(fiber.Map{"status": false, "message": err.Error()})
+			return
+		}
+		defer unsubscribe()
+
+		for job := range progress {
+			if err := c.WriteJSON(fiber.Map{"status": true, "data": job}); err != nil {
+				fmt.Println("Write error:", err)
+				break
+			}
+		}
+	}))
+	app.Post("/api/ollama/models/delete", func(c *fiber.Ctx) error {
+		data := new(struct {
+			Server string `json:"server"`
+			Model  string `json:"model"`
+		})
+		if err := c.BodyParser(&data); err != nil {
+			return err
+		}
+		if data.Model == "" {
+			return c.JSON(fiber.Map{"status": false, "message": "ollama model name is required"})
+		}
+		server, err := services.ResolveOllamaServer(cfg, data.Server)
+		if err != nil {
+			return c.JSON(fiber.Map{"status": false, "message": err.Error()})
+		}
+		if err := services.DeleteOllamaModel(server, data.Model); err != nil {
+			return c.JSON(fiber.Map{"status": false, "message": err.Error()})
+		}
+		go ollamaInventory.Refresh()
+		return c.JSON(fiber.Map{"status": true})
+	})
+	app.Post("/api/ollama/models/copy", func(c *fiber.Ctx) error {
+		data := new(struct {
+			Server      string `json:"server"`
+			Source      string `json:"source"`
+			Destination string `json:"destination"`
+		})
+		if err := c.BodyParser(&data); err != nil {
+			return err
+		}
+		if data.Source == "" || data.Destination == "" {
+			return c.JSON(fiber.Map{"status": false, "message": "source and destination are required"})
+		}
+		server, err := services.ResolveOllamaServer(cfg, data.Server)
+		if err != nil {
+			return c.JSON(fiber.Map{"status": false, "message": err.Error()})
+		}
+		if err := services.CopyOllamaModel(server, data.Source, data.Destination); err != nil {
+			return c.JSON(fiber.Map{"status": false, "message": err.Error()})
+		}
+		go ollamaInventory.Refresh()
+		return c.JSON(fiber.Map{"status": true})
+	})
+
+	app.Get("/api/ollama/evictions", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{
+			"status": true,
+			"data":   ollamaEvictor.Records(),
+		})
+	})
+
+	app.Get("/api/ollama/restarts", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{
+			"status": true,
+			"data":   ollamaSupervisor.Records(),
+		})
+	})
+
+	app.Get("/api/ollama/hung", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{
+			"status": true,
+			"data":   ollamaHung.States(),
+		})
+	})
+
+	app.Get("/api/ollama/probes", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{
+			"status": true,
+			"data":   probeResp,
+		})
+	})
+	app.Get("/api/ollama/probes/history", func(c *fiber.Ctx) error {
+		r := c.QueryInt("range", 3600)
+
+		start := time.Now().Add(time.Duration(-r) * time.Second).UnixMilli()
+		responstList, err := services.LoadSamples[models.OllamaProbeResponse](GPUSampleDB, "probe", start)
+		if err != nil {
+			return c.JSON(fiber.Map{
+				"status":  false,
+				"message": err.Error(),
+			})
+		}
+		return c.JSON(fiber.Map{
+			"status": true,
+			"data":   responstList,
+		})
+	})
+
+	app.Get("/api/ollama/health", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{
+			"status": true,
+			"data":   ollamaHealth.List(),
+		})
 	})
 
 	// 静态文件服务
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LanceLRQ/ollama-watchdog/configs"
+	"github.com/LanceLRQ/ollama-watchdog/models"
+	"github.com/dgraph-io/badger/v4"
+)
+
+// GPUCollector GPU数据采集器，不同厂商的实现（或测试替身）只需实现该接口即可接入监控
+type GPUCollector interface {
+	// Collect 采集一次GPU快照（GPU信息与进程列表）
+	Collect() (models.NvidiaSMIResponse, error)
+}
+
+// GPUCollectorFactory 根据配置创建采集器
+type GPUCollectorFactory func(cfg *configs.ServerConfigStruct) (GPUCollector, error)
+
+var (
+	gpuCollectorsMu sync.RWMutex
+	gpuCollectors   = map[string]GPUCollectorFactory{}
+)
+
+// RegisterGPUCollector 按名称注册GPU采集器，名称对应配置项 gpu_collector
+func RegisterGPUCollector(name string, factory GPUCollectorFactory) {
+	gpuCollectorsMu.Lock()
+	defer gpuCollectorsMu.Unlock()
+	if factory == nil {
+		panic("services: RegisterGPUCollector factory is nil")
+	}
+	if _, dup := gpuCollectors[name]; dup {
+		panic("services: RegisterGPUCollector called twice for " + name)
+	}
+	gpuCollectors[name] = factory
+}
+
+// GPUCollectorNames 获取已注册的采集器名称
+func GPUCollectorNames() []string {
+	gpuCollectorsMu.RLock()
+	defer gpuCollectorsMu.RUnlock()
+	names := make([]string, 0, len(gpuCollectors))
+	for name := range gpuCollectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewGPUCollector 根据配置项 gpu_collector 创建采集器
+func NewGPUCollector(cfg *configs.ServerConfigStruct) (GPUCollector, error) {
+	name := cfg.GPUCollector
+	if name == "" {
+		name = configs.DefaultGPUCollector
+	}
+	gpuCollectorsMu.RLock()
+	factory, ok := gpuCollectors[name]
+	gpuCollectorsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown gpu collector: %s (available: %s)", name, strings.Join(GPUCollectorNames(), ", "))
+	}
+	return factory(cfg)
+}
+
+// IsLocalGPUCollector 采集器输出的进程是否属于本机（PID 与本机 /proc 对应）
+//
+// nvidia_smi_command 通过 ssh / docker exec 等方式调用时，进程位于其他主机或 PID 命名空间中
+func IsLocalGPUCollector(cfg *configs.ServerConfigStruct) bool {
+	return strings.TrimSpace(cfg.NvidiaSmiCommand) == ""
+}
+
+// GPUWatcher 按指定间隔通过采集器获取GPU数据，enricher 不为 nil 时补充进程详情
+func GPUWatcher(collector GPUCollector, enricher *ProcessEnricher, interval time.Duration, callback func(models.NvidiaSMIResponse)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		response, err := collector.Collect()
+		if err != nil {
+			fmt.Println("Error collecting GPU info:", err)
+			continue
+		}
+		if enricher != nil {
+			enricher.Enrich(response.GPUProcesses)
+		}
+		now := time.Now()
+		response.Timestamp = now.Unix()
+		response.TimestampMs = now.UnixMilli()
+		callback(response)
+	}
+}
+
+func SaveSampleToDB(GPUSampleDB *badger.DB, nvidiaResp models.NvidiaSMIResponse, retention time.Duration) {
+	// 进程历史由 GPUProcessTracker 单独记录，采样中不保存进程列表
+	nvidiaResp.GPUProcesses = nil
+	// 以毫秒为键，支持亚秒级采样
+	if err := saveSample(GPUSampleDB, "gpu", nvidiaResp.TimestampMs, retention, nvidiaResp); err != nil {
+		fmt.Printf("%s\n", err.Error())
+	}
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/LanceLRQ/ollama-watchdog/configs"
+	"github.com/LanceLRQ/ollama-watchdog/models"
+	"github.com/dgraph-io/badger/v4"
+)
+
+// GPUProcessTracker 跟踪GPU进程的生命周期与显存峰值
+//
+// 只在进程出现、退出时写入事件（gpu_process 系列），退出事件带有完整的峰值记录；
+// 运行中进程的峰值保存在内存中，查询时与历史事件合并。
+//
+// 进程以 PID 和启动时间区分，避免 PID 复用时混淆；启动时间无法获取或来自远程采集（不可信）时，
+// 改用 PID 和首次出现时间区分，PID 从列表中消失或进程名变化即视为新进程
+type GPUProcessTracker struct {
+	db  *badger.DB
+	cfg *configs.ServerConfigStruct
+	// 进程在本机时启动时间才可信
+	trustStartTime bool
+
+	mu      sync.Mutex
+	running map[string]*models.GPUProcessRecord
+	// 没有启动时间的运行中进程，PID -> running 中的键
+	fallback map[uint64]string
+}
+
+func NewGPUProcessTracker(db *badger.DB, cfg *configs.ServerConfigStruct) *GPUProcessTracker {
+	return &GPUProcessTracker{
+		db:             db,
+		cfg:            cfg,
+		trustStartTime: IsLocalGPUCollector(cfg),
+		running:        map[string]*models.GPUProcessRecord{},
+		fallback:       map[uint64]string{},
+	}
+}
+
+// gpuProcessKey 有启动时间时用 PID+启动时间，否则用 PID+首次出现时间
+func gpuProcessKey(pid uint64, startTime int64, firstSeen int64) string {
+	if startTime > 0 {
+		return fmt.Sprintf("%d:%d", pid, startTime)
+	}
+	return fmt.Sprintf("%d@%d", pid, firstSeen)
+}
+
+// processKey 找到采样中的进程对应的运行中记录的键，调用方需持有锁
+func (t *GPUProcessTracker) processKey(process models.GPUProcess, startTime int64, timestamp int64) string {
+	if startTime > 0 {
+		return gpuProcessKey(process.PID, startTime, 0)
+	}
+	if key, ok := t.fallback[process.PID]; ok && t.running[key].Name == process.Name {
+		return key
+	}
+	key := gpuProcessKey(process.PID, 0, timestamp)
+	t.fallback[process.PID] = key
+	return key
+}
+
+// Update 根据最新的GPU采样更新进程记录
+func (t *GPUProcessTracker) Update(response models.NvidiaSMIResponse) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var started []string
+	// 同一进程可能同时使用多块GPU，显存按进程汇总
+	memory := map[string]uint64{}
+	for _, process := range response.GPUProcesses {
+		startTime := process.StartTime
+		if !t.trustStartTime {
+			startTime = 0
+		}
+		key := t.processKey(process, startTime, response.Timestamp)
+		record, ok := t.running[key]
+		if !ok {
+			record = &models.GPUProcessRecord{
+				PID:         process.PID,
+				Name:        process.Name,
+				User:        process.User,
+				Cmdline:     process.Cmdline,
+				Unit:        process.Unit,
+				ContainerId: process.ContainerId,
+				StartTime:   startTime,
+				FirstSeen:   response.Timestamp,
+				Running:     true,
+			}
+			t.running[key] = record
+			started = append(started, key)
+		}
+		if !slices.Contains(record.BusIds, process.BusId) {
+			record.BusIds = append(record.BusIds, process.BusId)
+		}
+		record.LastSeen = response.Timestamp
+		memory[key] += process.MemoryUsed
+	}
+	for key, used := range memory {
+		if record := t.running[key]; used > record.MemoryPeak {
+			record.MemoryPeak = used
+			record.MemoryPeakAt = response.Timestamp
+		}
+	}
+
+	var events []models.GPUProcessEvent
+	event := func(kind string, record *models.GPUProcessRecord) {
+		events = append(events, models.GPUProcessEvent{
+			GPUProcessRecord: *record,
+			Event:            kind,
+			Timestamp:        response.Timestamp,
+			TimestampMs:      response.TimestampMs,
+		})
+	}
+	for _, key := range started {
+		event(models.GPUProcessStarted, t.running[key])
+	}
+	for key, record := range t.running {
+		if _, ok := memory[key]; ok {
+			continue
+		}
+		record.Running = false
+		event(models.GPUProcessStopped, record)
+		delete(t.running, key)
+		if t.fallback[record.PID] == key {
+			delete(t.fallback, record.PID)
+		}
+	}
+
+	if len(events) > 0 {
+		if err := saveSample(t.db, "gpu_process", response.TimestampMs, t.cfg.RetentionOf("gpu_process"), events); err != nil {
+			fmt.Printf("%s\n", err.Error())
+		}
+	}
+}
+
+// History 查询 [startMs, endMs] 内运行过的GPU进程，每个进程一条记录，按首次出现时间排序
+//
+// pid 不为0时只返回该 PID，name 不为空时按进程名或命令行模糊匹配（不区分大小写）
+func (t *GPUProcessTracker) History(startMs int64, endMs int64, pid uint64, name string) ([]models.GPUProcessRecord, error) {
+	batches, err := LoadSamples[[]models.GPUProcessEvent](t.db, "gpu_process", startMs)
+	if err != nil {
+		return nil, err
+	}
+
+	records := map[string]models.GPUProcessRecord{}
+	for _, batch := range batches {
+		for _, e := range batch {
+			key := gpuProcessKey(e.PID, e.StartTime, e.FirstSeen)
+			// 结束事件的记录最完整；只有开始事件的进程可能仍在运行，或在看门狗停止期间退出
+			if _, ok := records[key]; !ok || e.Event == models.GPUProcessStopped {
+				record := e.GPUProcessRecord
+				record.Running = false
+				records[key] = record
+			}
+		}
+	}
+	t.mu.Lock()
+	for key, record := range t.running {
+		records[key] = *record
+	}
+	t.mu.Unlock()
+
+	name = strings.ToLower(name)
+	list := make([]models.GPUProcessRecord, 0, len(records))
+	for _, record := range records {
+		if pid != 0 && record.PID != pid {
+			continue
+		}
+		if name != "" && !strings.Contains(strings.ToLower(record.Name), name) && !strings.Contains(strings.ToLower(record.Cmdline), name) {
+			continue
+		}
+		// 只保留与查询范围有重叠的进程
+		if record.LastSeen*1000 < startMs || record.FirstSeen*1000 > endMs {
+			continue
+		}
+		list = append(list, record)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].FirstSeen != list[j].FirstSeen {
+			return list[i].FirstSeen < list[j].FirstSeen
+		}
+		return list[i].PID < list[j].PID
+	})
+	return list, nil
+}
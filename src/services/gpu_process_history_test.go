@@ -0,0 +1,171 @@
+package services
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/LanceLRQ/ollama-watchdog/configs"
+	"github.com/LanceLRQ/ollama-watchdog/models"
+	"github.com/dgraph-io/badger/v4"
+)
+
+func openTestDB(t *testing.T) *badger.DB {
+	t.Helper()
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func gpuProcessSample(timestamp int64, processes ...models.GPUProcess) models.NvidiaSMIResponse {
+	return models.NvidiaSMIResponse{
+		GPUProcesses: processes,
+		Timestamp:    timestamp,
+		TimestampMs:  timestamp * 1000,
+	}
+}
+
+// gpuProcessEvents 按写入顺序列出事件，格式为 start/stop:进程名
+func gpuProcessEvents(t *testing.T, db *badger.DB) []string {
+	t.Helper()
+	batches, err := LoadSamples[[]models.GPUProcessEvent](db, "gpu_process", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []string
+	for _, batch := range batches {
+		for _, e := range batch {
+			events = append(events, e.Event+":"+e.Name)
+		}
+	}
+	return events
+}
+
+func TestGPUProcessTrackerLocal(t *testing.T) {
+	db := openTestDB(t)
+	cfg := configs.GetDefaultServerConfig()
+	tracker := NewGPUProcessTracker(db, &cfg)
+
+	const gpuA, gpuB = "00000000:01:00.0", "00000000:02:00.0"
+	tracker.Update(gpuProcessSample(100,
+		models.GPUProcess{BusId: gpuA, PID: 10, Name: "runner", MemoryUsed: 1000, StartTime: 50},
+		models.GPUProcess{BusId: gpuB, PID: 10, Name: "runner", MemoryUsed: 2000, StartTime: 50},
+		models.GPUProcess{BusId: gpuA, PID: 20, Name: "python", MemoryUsed: 500, StartTime: 60},
+	))
+	tracker.Update(gpuProcessSample(101,
+		models.GPUProcess{BusId: gpuA, PID: 10, Name: "runner", MemoryUsed: 1500, StartTime: 50},
+		models.GPUProcess{BusId: gpuB, PID: 10, Name: "runner", MemoryUsed: 2500, StartTime: 50},
+	))
+	// PID 20 被新进程复用
+	tracker.Update(gpuProcessSample(102,
+		models.GPUProcess{BusId: gpuA, PID: 10, Name: "runner", MemoryUsed: 1000, StartTime: 50},
+		models.GPUProcess{BusId: gpuA, PID: 20, Name: "trainer", MemoryUsed: 700, StartTime: 90},
+	))
+
+	records, err := tracker.History(0, math.MaxInt64, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.GPUProcessRecord{
+		{PID: 10, Name: "runner", BusIds: []string{gpuA, gpuB}, StartTime: 50, FirstSeen: 100, LastSeen: 102, MemoryPeak: 4000, MemoryPeakAt: 101, Running: true},
+		{PID: 20, Name: "python", BusIds: []string{gpuA}, StartTime: 60, FirstSeen: 100, LastSeen: 100, MemoryPeak: 500, MemoryPeakAt: 100},
+		{PID: 20, Name: "trainer", BusIds: []string{gpuA}, StartTime: 90, FirstSeen: 102, LastSeen: 102, MemoryPeak: 700, MemoryPeakAt: 102, Running: true},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("running history:\n got %+v\nwant %+v", records, want)
+	}
+
+	tracker.Update(gpuProcessSample(103))
+	records, err = tracker.History(0, math.MaxInt64, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want[0].Running = false
+	want[2].Running = false
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("stopped history:\n got %+v\nwant %+v", records, want)
+	}
+	events := gpuProcessEvents(t, db)
+	wantEvents := []string{"start:runner", "start:python", "stop:python", "start:trainer", "stop:runner", "stop:trainer"}
+	if len(events) != len(wantEvents) {
+		t.Fatalf("events = %v, want %v", events, wantEvents)
+	}
+	// 同一批内的事件顺序不固定，只比较各批次的集合
+	for _, batch := range [][2]int{{0, 2}, {2, 4}, {4, 6}} {
+		got := map[string]bool{}
+		for _, e := range events[batch[0]:batch[1]] {
+			got[e] = true
+		}
+		for _, e := range wantEvents[batch[0]:batch[1]] {
+			if !got[e] {
+				t.Errorf("events = %v, want %v", events, wantEvents)
+			}
+		}
+	}
+
+	if records, _ := tracker.History(0, math.MaxInt64, 20, ""); len(records) != 2 {
+		t.Errorf("pid filter returned %d records, want 2", len(records))
+	}
+	if records, _ := tracker.History(0, math.MaxInt64, 0, "TRAIN"); len(records) != 1 || records[0].Name != "trainer" {
+		t.Errorf("name filter returned %+v", records)
+	}
+
+	// 按时间范围查询：只返回与范围有重叠的进程
+	ranges := []struct {
+		start, end int64
+		want       []string
+	}{
+		{101000, 101000, []string{"runner"}},
+		{102000, math.MaxInt64, []string{"runner", "trainer"}},
+		{0, 100000, []string{"runner", "python"}},
+		{104000, math.MaxInt64, nil},
+	}
+	for _, r := range ranges {
+		records, err := tracker.History(r.start, r.end, 0, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, record := range records {
+			names = append(names, record.Name)
+		}
+		if !reflect.DeepEqual(names, r.want) {
+			t.Errorf("History(%d, %d) = %v, want %v", r.start, r.end, names, r.want)
+		}
+	}
+}
+
+func TestGPUProcessTrackerRemote(t *testing.T) {
+	db := openTestDB(t)
+	cfg := configs.GetDefaultServerConfig()
+	cfg.NvidiaSmiCommand = "ssh gpu-node nvidia-smi"
+	tracker := NewGPUProcessTracker(db, &cfg)
+
+	// 远程采集时 StartTime 不可信（可能来自本机同 PID 的其他进程），变化不应拆分记录
+	tracker.Update(gpuProcessSample(100, models.GPUProcess{BusId: "a", PID: 30, Name: "ollama", MemoryUsed: 100, StartTime: 1}))
+	tracker.Update(gpuProcessSample(101, models.GPUProcess{BusId: "a", PID: 30, Name: "ollama", MemoryUsed: 300, StartTime: 2}))
+	// 进程名变化：PID 被复用
+	tracker.Update(gpuProcessSample(102, models.GPUProcess{BusId: "a", PID: 30, Name: "python", MemoryUsed: 200}))
+	tracker.Update(gpuProcessSample(103))
+	// PID 消失后再次出现：新进程
+	tracker.Update(gpuProcessSample(104, models.GPUProcess{BusId: "a", PID: 30, Name: "python", MemoryUsed: 50}))
+
+	records, err := tracker.History(0, math.MaxInt64, 30, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.GPUProcessRecord{
+		{PID: 30, Name: "ollama", BusIds: []string{"a"}, FirstSeen: 100, LastSeen: 101, MemoryPeak: 300, MemoryPeakAt: 101},
+		{PID: 30, Name: "python", BusIds: []string{"a"}, FirstSeen: 102, LastSeen: 102, MemoryPeak: 200, MemoryPeakAt: 102},
+		{PID: 30, Name: "python", BusIds: []string{"a"}, FirstSeen: 104, LastSeen: 104, MemoryPeak: 50, MemoryPeakAt: 104, Running: true},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("history:\n got %+v\nwant %+v", records, want)
+	}
+	if len(tracker.fallback) != 1 {
+		t.Errorf("fallback keys leaked: %v", tracker.fallback)
+	}
+}
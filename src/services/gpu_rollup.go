@@ -0,0 +1,248 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/LanceLRQ/ollama-watchdog/configs"
+	"github.com/LanceLRQ/ollama-watchdog/models"
+	"github.com/dgraph-io/badger/v4"
+)
+
+// 历史数据分辨率，原始采样与聚合数据分别存放在 gpu、gpu_1m、gpu_1h 系列下
+const (
+	GPUHistoryRaw    = "raw"
+	GPUHistoryMinute = "1m"
+	GPUHistoryHour   = "1h"
+)
+
+// gpuRollupMetric 参与聚合的指标，name 与 GPUInfo 的 json 字段名一致
+type gpuRollupMetric struct {
+	name string
+	get  func(info models.GPUInfo) float64
+	set  func(info *models.GPUInfo, value float64)
+}
+
+func uintMetric(name string, field func(info *models.GPUInfo) *uint64) gpuRollupMetric {
+	return gpuRollupMetric{
+		name: name,
+		get:  func(info models.GPUInfo) float64 { return float64(*field(&info)) },
+		set:  func(info *models.GPUInfo, value float64) { *field(info) = uint64(math.Round(value)) },
+	}
+}
+
+var gpuRollupMetrics = []gpuRollupMetric{
+	uintMetric("mem_used", func(info *models.GPUInfo) *uint64 { return &info.MemoryUsed }),
+	uintMetric("gpu_used", func(info *models.GPUInfo) *uint64 { return &info.GPUUsed }),
+	uintMetric("temperature", func(info *models.GPUInfo) *uint64 { return &info.Temperature }),
+	{
+		name: "power_usage",
+		get:  func(info models.GPUInfo) float64 { return info.PowerUsage },
+		set:  func(info *models.GPUInfo, value float64) { info.PowerUsage = value },
+	},
+	uintMetric("clock_sm", func(info *models.GPUInfo) *uint64 { return &info.ClockSM }),
+	uintMetric("clock_mem", func(info *models.GPUInfo) *uint64 { return &info.ClockMemory }),
+	uintMetric("fan_speed", func(info *models.GPUInfo) *uint64 { return &info.FanSpeed }),
+	uintMetric("pcie_tx", func(info *models.GPUInfo) *uint64 { return &info.PCIeTxThroughput }),
+	uintMetric("pcie_rx", func(info *models.GPUInfo) *uint64 { return &info.PCIeRxThroughput }),
+	uintMetric("encoder_used", func(info *models.GPUInfo) *uint64 { return &info.EncoderUsed }),
+	uintMetric("decoder_used", func(info *models.GPUInfo) *uint64 { return &info.DecoderUsed }),
+	uintMetric("mem_bandwidth_used", func(info *models.GPUInfo) *uint64 { return &info.MemoryBandwidth }),
+}
+
+// GPURollupWatcher 每分钟将原始GPU采样聚合为1分钟数据，再将1分钟数据聚合为1小时数据
+func GPURollupWatcher(db *badger.DB, cfg *configs.ServerConfigStruct) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		if err := RunGPURollups(db, cfg, time.Now()); err != nil {
+			fmt.Println("Error rolling up GPU samples:", err)
+		}
+		<-ticker.C
+	}
+}
+
+// RunGPURollups 聚合 now 之前所有已结束且尚未聚合的时间段
+func RunGPURollups(db *badger.DB, cfg *configs.ServerConfigStruct, now time.Time) error {
+	err := rollupGPUSeries(db, "gpu", "gpu_"+GPUHistoryMinute, time.Minute, now, cfg.RetentionOf("gpu_"+GPUHistoryMinute), func(startMs, endMs int64) (models.GPURollup, error) {
+		samples, err := LoadSampleRange[models.NvidiaSMIResponse](db, "gpu", startMs, endMs)
+		if err != nil {
+			return models.GPURollup{}, err
+		}
+		return RollupGPUSamples(GPUHistoryMinute, startMs, samples), nil
+	})
+	if err != nil {
+		return err
+	}
+	return rollupGPUSeries(db, "gpu_"+GPUHistoryMinute, "gpu_"+GPUHistoryHour, time.Hour, now, cfg.RetentionOf("gpu_"+GPUHistoryHour), func(startMs, endMs int64) (models.GPURollup, error) {
+		rollups, err := LoadSampleRange[models.GPURollup](db, "gpu_"+GPUHistoryMinute, startMs, endMs)
+		if err != nil {
+			return models.GPURollup{}, err
+		}
+		return MergeGPURollups(GPUHistoryHour, startMs, rollups), nil
+	})
+}
+
+// rollupGPUSeries 从 target 中最后一个时间段之后开始，逐个聚合 source 中有数据的时间段
+func rollupGPUSeries(db *badger.DB, source string, target string, bucket time.Duration, now time.Time, retention time.Duration, aggregate func(startMs, endMs int64) (models.GPURollup, error)) error {
+	size := bucket.Milliseconds()
+	cursor := int64(0)
+	if last, ok := lastSampleTime(db, target); ok {
+		cursor = last + size
+	}
+	// 只聚合已经结束的时间段
+	end := now.UnixMilli() - now.UnixMilli()%size
+	for {
+		ts, ok := firstSampleTime(db, source, cursor)
+		if !ok || ts >= end {
+			return nil
+		}
+		start := ts - ts%size
+		rollup, err := aggregate(start, start+size)
+		if err != nil {
+			return err
+		}
+		if rollup.Samples > 0 {
+			if err := saveSample(db, target, start, retention, rollup); err != nil {
+				return err
+			}
+		}
+		cursor = start + size
+	}
+}
+
+// RollupGPUSamples 将一个时间段内的原始采样聚合为每个GPU各指标的 min/max/avg/p95
+func RollupGPUSamples(resolution string, startMs int64, samples []models.NvidiaSMIResponse) models.GPURollup {
+	acc := newGPURollupAccumulator()
+	for _, sample := range samples {
+		for _, info := range sample.GPUInfo {
+			acc.add(info, 1, func(metric gpuRollupMetric) models.GPUMetricStat {
+				v := metric.get(info)
+				return models.GPUMetricStat{Min: v, Max: v, Avg: v, P95: v}
+			})
+		}
+	}
+	return acc.result(resolution, startMs, len(samples))
+}
+
+// MergeGPURollups 将多个较细粒度的聚合数据合并为一个时间段
+//
+// 平均值按采样数加权；p95 无法由分段结果精确还原，取各分段 p95 的 p95，结果略偏高
+func MergeGPURollups(resolution string, startMs int64, rollups []models.GPURollup) models.GPURollup {
+	acc := newGPURollupAccumulator()
+	samples := 0
+	for _, rollup := range rollups {
+		samples += rollup.Samples
+		for _, stat := range rollup.Stats {
+			i := slices.IndexFunc(rollup.GPUInfo, func(info models.GPUInfo) bool { return info.BusId == stat.BusId })
+			if i < 0 {
+				continue
+			}
+			acc.add(rollup.GPUInfo[i], rollup.Samples, func(metric gpuRollupMetric) models.GPUMetricStat {
+				return stat.Metrics[metric.name]
+			})
+		}
+	}
+	return acc.result(resolution, startMs, samples)
+}
+
+type gpuRollupAccumulator struct {
+	busIds []string
+	last   map[string]models.GPUInfo
+	values map[string]map[string]*gpuMetricValues
+}
+
+type gpuMetricValues struct {
+	min, max, sum float64
+	weight        int
+	p95s          []float64
+}
+
+func newGPURollupAccumulator() *gpuRollupAccumulator {
+	return &gpuRollupAccumulator{
+		last:   map[string]models.GPUInfo{},
+		values: map[string]map[string]*gpuMetricValues{},
+	}
+}
+
+// add 累加一个GPU的一组统计值，weight 为该组统计值代表的采样数
+func (a *gpuRollupAccumulator) add(info models.GPUInfo, weight int, stat func(metric gpuRollupMetric) models.GPUMetricStat) {
+	if _, ok := a.values[info.BusId]; !ok {
+		a.busIds = append(a.busIds, info.BusId)
+		a.values[info.BusId] = map[string]*gpuMetricValues{}
+	}
+	a.last[info.BusId] = info
+	for _, metric := range gpuRollupMetrics {
+		s := stat(metric)
+		v, ok := a.values[info.BusId][metric.name]
+		if !ok {
+			v = &gpuMetricValues{min: s.Min, max: s.Max}
+			a.values[info.BusId][metric.name] = v
+		}
+		v.min = min(v.min, s.Min)
+		v.max = max(v.max, s.Max)
+		v.sum += s.Avg * float64(weight)
+		v.weight += weight
+		v.p95s = append(v.p95s, s.P95)
+	}
+}
+
+func (a *gpuRollupAccumulator) result(resolution string, startMs int64, samples int) models.GPURollup {
+	rollup := models.GPURollup{
+		Resolution:  resolution,
+		Samples:     samples,
+		GPUInfo:     make([]models.GPUInfo, 0, len(a.busIds)),
+		Stats:       make([]models.GPURollupStat, 0, len(a.busIds)),
+		Timestamp:   startMs / 1000,
+		TimestampMs: startMs,
+	}
+	for _, busId := range a.busIds {
+		// 非统计字段（名称、显存总量、功耗上限等）取时间段内最后一次的值
+		info := a.last[busId]
+		info.ThrottleReasons = nil
+		stat := models.GPURollupStat{BusId: busId, Metrics: map[string]models.GPUMetricStat{}}
+		for _, metric := range gpuRollupMetrics {
+			v := a.values[busId][metric.name]
+			s := models.GPUMetricStat{Min: v.min, Max: v.max, P95: percentile(v.p95s, 0.95)}
+			if v.weight > 0 {
+				s.Avg = v.sum / float64(v.weight)
+			}
+			metric.set(&info, s.Avg)
+			stat.Metrics[metric.name] = s
+		}
+		rollup.GPUInfo = append(rollup.GPUInfo, info)
+		rollup.Stats = append(rollup.Stats, stat)
+	}
+	return rollup
+}
+
+// percentile 按最近秩法计算百分位数
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(0, rank)]
+}
+
+// GPUHistoryResolution 根据查询范围自动选择历史数据分辨率：
+// 2小时以内用原始采样，2天以内用1分钟聚合，更长用1小时聚合；较细的数据已过期时改用更粗的分辨率
+func GPUHistoryResolution(cfg *configs.ServerConfigStruct, rangeSec int) string {
+	r := time.Duration(rangeSec) * time.Second
+	covers := func(series string) bool {
+		retention := cfg.RetentionOf(series)
+		return retention == 0 || retention >= r
+	}
+	if r <= 2*time.Hour && covers("gpu") {
+		return GPUHistoryRaw
+	}
+	if r <= 48*time.Hour && covers("gpu_"+GPUHistoryMinute) {
+		return GPUHistoryMinute
+	}
+	return GPUHistoryHour
+}
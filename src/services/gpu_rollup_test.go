@@ -0,0 +1,173 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/LanceLRQ/ollama-watchdog/configs"
+	"github.com/LanceLRQ/ollama-watchdog/models"
+	"github.com/dgraph-io/badger/v4"
+)
+
+func TestPercentile(t *testing.T) {
+	tests := []struct {
+		values []float64
+		p      float64
+		want   float64
+	}{
+		{nil, 0.95, 0},
+		{[]float64{7}, 0.95, 7},
+		{[]float64{3, 1, 2}, 0.5, 2},
+		{[]float64{5, 1, 4, 2, 3}, 0.95, 5},
+		{[]float64{10, 20, 30, 40, 50, 60, 70, 80, 90, 100}, 0.95, 100},
+		{[]float64{10, 20, 30, 40, 50, 60, 70, 80, 90, 100}, 0.9, 90},
+		{[]float64{4, 3, 2, 1}, 0, 1},
+	}
+	for _, tt := range tests {
+		if got := percentile(tt.values, tt.p); got != tt.want {
+			t.Errorf("percentile(%v, %v) = %v, want %v", tt.values, tt.p, got, tt.want)
+		}
+	}
+}
+
+func gpuUsedSample(timestampMs int64, used ...uint64) models.NvidiaSMIResponse {
+	sample := models.NvidiaSMIResponse{Timestamp: timestampMs / 1000, TimestampMs: timestampMs}
+	for i, u := range used {
+		sample.GPUInfo = append(sample.GPUInfo, models.GPUInfo{
+			BusId:       fmt.Sprintf("0000000%d:01:00.0", i),
+			GPUUsed:     u,
+			MemoryTotal: 24576,
+			MemoryUsed:  u * 100,
+		})
+	}
+	return sample
+}
+
+func rollupStat(rollup models.GPURollup, busId string, metric string) models.GPUMetricStat {
+	for _, stat := range rollup.Stats {
+		if stat.BusId == busId {
+			return stat.Metrics[metric]
+		}
+	}
+	return models.GPUMetricStat{}
+}
+
+func TestRollupGPUSamples(t *testing.T) {
+	var samples []models.NvidiaSMIResponse
+	for i, used := range []uint64{10, 20, 30, 40, 100} {
+		samples = append(samples, gpuUsedSample(int64(60000+i*1000), used, 5))
+	}
+	rollup := RollupGPUSamples(GPUHistoryMinute, 60000, samples)
+
+	if rollup.Samples != 5 || rollup.Resolution != GPUHistoryMinute || rollup.TimestampMs != 60000 || rollup.Timestamp != 60 {
+		t.Fatalf("unexpected rollup header: %+v", rollup)
+	}
+	if len(rollup.GPUInfo) != 2 || len(rollup.Stats) != 2 {
+		t.Fatalf("expected 2 GPUs, got %d infos and %d stats", len(rollup.GPUInfo), len(rollup.Stats))
+	}
+	tests := []struct {
+		busId  string
+		metric string
+		want   models.GPUMetricStat
+	}{
+		{"00000000:01:00.0", "gpu_used", models.GPUMetricStat{Min: 10, Max: 100, Avg: 40, P95: 100}},
+		{"00000000:01:00.0", "mem_used", models.GPUMetricStat{Min: 1000, Max: 10000, Avg: 4000, P95: 10000}},
+		{"00000001:01:00.0", "gpu_used", models.GPUMetricStat{Min: 5, Max: 5, Avg: 5, P95: 5}},
+	}
+	for _, tt := range tests {
+		if got := rollupStat(rollup, tt.busId, tt.metric); got != tt.want {
+			t.Errorf("%s %s = %+v, want %+v", tt.busId, tt.metric, got, tt.want)
+		}
+	}
+	// GPUInfo 中的指标为平均值，非统计字段保留原值
+	if info := rollup.GPUInfo[0]; info.GPUUsed != 40 || info.MemoryUsed != 4000 || info.MemoryTotal != 24576 {
+		t.Errorf("unexpected averaged info: %+v", info)
+	}
+}
+
+func TestMergeGPURollups(t *testing.T) {
+	first := RollupGPUSamples(GPUHistoryMinute, 0, []models.NvidiaSMIResponse{
+		gpuUsedSample(0, 10), gpuUsedSample(1000, 20), gpuUsedSample(2000, 30),
+	})
+	second := RollupGPUSamples(GPUHistoryMinute, 60000, []models.NvidiaSMIResponse{
+		gpuUsedSample(60000, 90),
+	})
+	third := RollupGPUSamples(GPUHistoryMinute, 120000, []models.NvidiaSMIResponse{
+		gpuUsedSample(120000, 50), gpuUsedSample(121000, 70),
+	})
+	hour := MergeGPURollups(GPUHistoryHour, 0, []models.GPURollup{first, second, third})
+
+	if hour.Samples != 6 || hour.Resolution != GPUHistoryHour {
+		t.Fatalf("unexpected rollup header: %+v", hour)
+	}
+	// 平均值按采样数加权：(10+20+30+90+50+70)/6 = 45；p95 取各分段 p95（30、90、70）的 p95
+	want := models.GPUMetricStat{Min: 10, Max: 90, Avg: 45, P95: 90}
+	if got := rollupStat(hour, "00000000:01:00.0", "gpu_used"); got != want {
+		t.Errorf("gpu_used = %+v, want %+v", got, want)
+	}
+	if hour.GPUInfo[0].GPUUsed != 45 {
+		t.Errorf("averaged gpu_used = %d, want 45", hour.GPUInfo[0].GPUUsed)
+	}
+
+	if empty := MergeGPURollups(GPUHistoryHour, 0, nil); empty.Samples != 0 || len(empty.Stats) != 0 {
+		t.Errorf("merging nothing should be empty: %+v", empty)
+	}
+}
+
+func TestRunGPURollupsSkipsLegacyKeys(t *testing.T) {
+	db := openTestDB(t)
+	cfg := configs.GetDefaultServerConfig()
+	now := time.Date(2026, 10, 1, 12, 30, 0, 0, time.UTC)
+	start := now.Add(-30 * time.Minute)
+
+	// 升级前以秒为单位写入的原始采样，以及据此生成的错误聚合
+	err := db.Update(func(txn *badger.Txn) error {
+		for _, key := range []string{
+			fmt.Sprintf("gpu:%d", start.Add(-10*time.Minute).Unix()),
+			fmt.Sprintf("gpu_1m:%d", start.Add(-10*time.Minute).Unix()),
+		} {
+			if err := txn.Set([]byte(key), []byte(`{}`)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ts := start; ts.Before(now); ts = ts.Add(10 * time.Second) {
+		if err := saveSample(db, "gpu", ts.UnixMilli(), 0, gpuUsedSample(ts.UnixMilli(), 50)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := RunGPURollups(db, &cfg, now); err != nil {
+		t.Fatal(err)
+	}
+	minutes, err := LoadSamples[models.GPURollup](db, "gpu_"+GPUHistoryMinute, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(minutes) != 30 {
+		t.Fatalf("got %d minute rollups, want 30", len(minutes))
+	}
+	for i, rollup := range minutes {
+		if want := start.Add(time.Duration(i) * time.Minute).UnixMilli(); rollup.TimestampMs != want || rollup.Samples != 6 {
+			t.Errorf("rollup %d: timestamp %d samples %d, want %d and 6", i, rollup.TimestampMs, rollup.Samples, want)
+		}
+	}
+
+	// 后续运行从最后一个聚合之后继续
+	for ts := now; ts.Before(now.Add(2 * time.Minute)); ts = ts.Add(10 * time.Second) {
+		if err := saveSample(db, "gpu", ts.UnixMilli(), 0, gpuUsedSample(ts.UnixMilli(), 50)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := RunGPURollups(db, &cfg, now.Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if last, ok := lastSampleTime(db, "gpu_"+GPUHistoryMinute); !ok || last != now.Add(time.Minute).UnixMilli() {
+		t.Errorf("last minute rollup at %d, want %d", last, now.Add(time.Minute).UnixMilli())
+	}
+}
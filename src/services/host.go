@@ -0,0 +1,303 @@
+package services
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LanceLRQ/ollama-watchdog/models"
+	"github.com/LanceLRQ/ollama-watchdog/utils"
+	"github.com/dgraph-io/badger/v4"
+)
+
+// HostCollector 通过 /proc 采集主机CPU、内存、交换分区、磁盘与网络指标（仅支持Linux）
+//
+// CPU利用率与磁盘、网络速率由两次采样的计数器差值计算，首次采样时这些值为0
+type HostCollector struct {
+	ProcPath string // 默认为 /proc
+	SysPath  string // 默认为 /sys，用于区分整盘与分区
+
+	mu       sync.Mutex
+	prevAt   time.Time
+	prevCPU  hostCPUCounters
+	prevDisk map[string]hostDiskCounters
+	prevNet  map[string]hostNetCounters
+}
+
+type hostCPUCounters struct {
+	total  uint64
+	idle   uint64
+	iowait uint64
+}
+
+type hostDiskCounters struct {
+	sectorsRead    uint64
+	sectorsWritten uint64
+	ioMs           uint64
+}
+
+type hostNetCounters struct {
+	rx uint64
+	tx uint64
+}
+
+func NewHostCollector() *HostCollector {
+	return &HostCollector{ProcPath: "/proc", SysPath: "/sys"}
+}
+
+// HostWatcher 按指定间隔采集主机指标，系统不支持（没有 /proc）时提示一次后停止采集
+func HostWatcher(collector *HostCollector, interval time.Duration, callback func(models.HostMetrics)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		metrics, err := collector.Collect()
+		if errors.Is(err, fs.ErrNotExist) {
+			fmt.Println("Host metrics collector disabled, /proc is not available:", err)
+			return
+		}
+		if err != nil {
+			fmt.Println("Error collecting host metrics:", err)
+			continue
+		}
+		callback(metrics)
+	}
+}
+
+func SaveHostSampleToDB(db *badger.DB, metrics models.HostMetrics, retention time.Duration) {
+	if err := saveSample(db, "host", metrics.TimestampMs, retention, metrics); err != nil {
+		fmt.Printf("%s\n", err.Error())
+	}
+}
+
+func (h *HostCollector) Collect() (models.HostMetrics, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	metrics := models.HostMetrics{
+		Timestamp:   now.Unix(),
+		TimestampMs: now.UnixMilli(),
+	}
+
+	cpu, cores, err := h.readStat()
+	if err != nil {
+		return metrics, err
+	}
+	metrics.CPUCores = cores
+	if err := h.readMeminfo(&metrics); err != nil {
+		return metrics, err
+	}
+	if err := h.readLoadavg(&metrics); err != nil {
+		return metrics, err
+	}
+	disks, err := h.readDiskstats()
+	if err != nil {
+		return metrics, err
+	}
+	nets, err := h.readNetDev()
+	if err != nil {
+		return metrics, err
+	}
+
+	if !h.prevAt.IsZero() {
+		elapsed := now.Sub(h.prevAt).Seconds()
+		if total := cpu.total - h.prevCPU.total; total > 0 && cpu.total >= h.prevCPU.total {
+			idle := float64(cpu.idle - h.prevCPU.idle)
+			metrics.CPUUsed = 100 * (1 - idle/float64(total))
+			metrics.CPUIOWait = 100 * float64(cpu.iowait-h.prevCPU.iowait) / float64(total)
+		}
+		for _, name := range slices.Sorted(maps.Keys(disks)) {
+			cur, prev := disks[name], h.prevDisk[name]
+			if _, ok := h.prevDisk[name]; !ok || cur.ioMs < prev.ioMs {
+				continue
+			}
+			metrics.Disks = append(metrics.Disks, models.HostDiskStat{
+				Name:       name,
+				ReadBytes:  uint64(float64((cur.sectorsRead-prev.sectorsRead)*512) / elapsed),
+				WriteBytes: uint64(float64((cur.sectorsWritten-prev.sectorsWritten)*512) / elapsed),
+				IOUtil:     min(100, float64(cur.ioMs-prev.ioMs)/(elapsed*10)),
+			})
+		}
+		for _, name := range slices.Sorted(maps.Keys(nets)) {
+			cur, prev := nets[name], h.prevNet[name]
+			if _, ok := h.prevNet[name]; !ok || cur.rx < prev.rx || cur.tx < prev.tx {
+				continue
+			}
+			metrics.Networks = append(metrics.Networks, models.HostNetStat{
+				Name:    name,
+				RxBytes: uint64(float64(cur.rx-prev.rx) / elapsed),
+				TxBytes: uint64(float64(cur.tx-prev.tx) / elapsed),
+			})
+		}
+	}
+
+	h.prevAt = now
+	h.prevCPU = cpu
+	h.prevDisk = disks
+	h.prevNet = nets
+	return metrics, nil
+}
+
+func (h *HostCollector) readLines(name string) ([]string, error) {
+	f, err := os.Open(filepath.Join(h.ProcPath, name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// readStat 解析 /proc/stat 中的 cpu 汇总行，并统计 cpuN 行数作为核心数
+func (h *HostCollector) readStat() (hostCPUCounters, int, error) {
+	lines, err := h.readLines("stat")
+	if err != nil {
+		return hostCPUCounters{}, 0, err
+	}
+	var counters hostCPUCounters
+	cores := 0
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 5 || !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
+		if fields[0] != "cpu" {
+			cores++
+			continue
+		}
+		// user nice system idle iowait irq softirq steal，guest 已计入 user
+		for i := 1; i < len(fields) && i <= 8; i++ {
+			counters.total += utils.ParseUint(fields[i])
+		}
+		counters.idle = utils.ParseUint(fields[4])
+		if len(fields) > 5 {
+			counters.iowait = utils.ParseUint(fields[5])
+			counters.idle += counters.iowait
+		}
+	}
+	if counters.total == 0 {
+		return counters, cores, fmt.Errorf("invalid /proc/stat format")
+	}
+	return counters, cores, nil
+}
+
+func (h *HostCollector) readMeminfo(metrics *models.HostMetrics) error {
+	lines, err := h.readLines("meminfo")
+	if err != nil {
+		return err
+	}
+	values := map[string]uint64{}
+	for _, line := range lines {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		// 单位为kB
+		values[key] = utils.ParseUint(value) / 1024
+	}
+	metrics.MemTotal = values["MemTotal"]
+	metrics.MemAvailable = values["MemAvailable"]
+	if metrics.MemTotal > metrics.MemAvailable {
+		metrics.MemUsed = metrics.MemTotal - metrics.MemAvailable
+	}
+	metrics.SwapTotal = values["SwapTotal"]
+	if metrics.SwapTotal > values["SwapFree"] {
+		metrics.SwapUsed = metrics.SwapTotal - values["SwapFree"]
+	}
+	return nil
+}
+
+func (h *HostCollector) readLoadavg(metrics *models.HostMetrics) error {
+	lines, err := h.readLines("loadavg")
+	if err != nil {
+		return err
+	}
+	if len(lines) == 0 {
+		return fmt.Errorf("invalid /proc/loadavg format")
+	}
+	fields := strings.Fields(lines[0])
+	if len(fields) < 3 {
+		return fmt.Errorf("invalid /proc/loadavg format")
+	}
+	metrics.Load1 = utils.ParseFloat(fields[0])
+	metrics.Load5 = utils.ParseFloat(fields[1])
+	metrics.Load15 = utils.ParseFloat(fields[2])
+	return nil
+}
+
+// readDiskstats 解析 /proc/diskstats，只保留 /sys/block 下的整盘设备（忽略分区、loop、ram）
+//
+// 部分容器环境没有 diskstats，此时不采集磁盘
+func (h *HostCollector) readDiskstats() (map[string]hostDiskCounters, error) {
+	lines, err := h.readLines("diskstats")
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string]hostDiskCounters{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	_, sysErr := os.Stat(filepath.Join(h.SysPath, "block"))
+	disks := map[string]hostDiskCounters{}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 13 {
+			continue
+		}
+		name := fields[2]
+		if strings.HasPrefix(name, "loop") || strings.HasPrefix(name, "ram") {
+			continue
+		}
+		if sysErr == nil {
+			if _, err := os.Stat(filepath.Join(h.SysPath, "block", name)); err != nil {
+				continue
+			}
+		}
+		disks[name] = hostDiskCounters{
+			sectorsRead:    utils.ParseUint(fields[5]),
+			sectorsWritten: utils.ParseUint(fields[9]),
+			ioMs:           utils.ParseUint(fields[12]),
+		}
+	}
+	return disks, nil
+}
+
+// readNetDev 解析 /proc/net/dev，忽略回环网卡；没有 net/dev 时不采集网络
+func (h *HostCollector) readNetDev() (map[string]hostNetCounters, error) {
+	lines, err := h.readLines("net/dev")
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string]hostNetCounters{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	nets := map[string]hostNetCounters{}
+	for _, line := range lines {
+		name, data, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		name = strings.TrimSpace(name)
+		fields := strings.Fields(data)
+		if name == "lo" || len(fields) < 9 {
+			continue
+		}
+		nets[name] = hostNetCounters{
+			rx: utils.ParseUint(fields[0]),
+			tx: utils.ParseUint(fields[8]),
+		}
+	}
+	return nets, nil
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LanceLRQ/ollama-watchdog/models"
+)
+
+// copyHostFixture 复制 testdata/host 到临时目录，返回对应的采集器
+func copyHostFixture(t *testing.T) (*HostCollector, string) {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.CopyFS(root, os.DirFS("testdata/host")); err != nil {
+		t.Fatal(err)
+	}
+	return &HostCollector{ProcPath: filepath.Join(root, "proc"), SysPath: filepath.Join(root, "sys")}, root
+}
+
+// replaceHostFixture 替换 proc 下某个文件中的一行
+func replaceHostFixture(t *testing.T, root string, name string, old string, new string) {
+	t.Helper()
+	path := filepath.Join(root, "proc", name)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), old) {
+		t.Fatalf("%s does not contain %q", name, old)
+	}
+	if err := os.WriteFile(path, []byte(strings.Replace(string(data), old, new, 1)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func approxEqual(got float64, want float64) bool {
+	return math.Abs(got-want) <= math.Max(0.01*math.Abs(want), 0.01)
+}
+
+func TestHostCollectorFirstSample(t *testing.T) {
+	collector := &HostCollector{ProcPath: "testdata/host/proc", SysPath: "testdata/host/sys"}
+	metrics, err := collector.Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := models.HostMetrics{
+		CPUCores:     4,
+		Load1:        1.25,
+		Load5:        0.8,
+		Load15:       0.5,
+		MemTotal:     64000,
+		MemUsed:      16000,
+		MemAvailable: 48000,
+		SwapTotal:    8192,
+		SwapUsed:     1024,
+		Timestamp:    metrics.Timestamp,
+		TimestampMs:  metrics.TimestampMs,
+	}
+	// 首次采样没有差值，CPU利用率和速率均为0
+	if metrics.CPUUsed != 0 || metrics.Disks != nil || metrics.Networks != nil {
+		t.Errorf("first sample should have no rates: %+v", metrics)
+	}
+	if !reflect.DeepEqual(metrics, want) {
+		t.Errorf("metrics = %+v, want %+v", metrics, want)
+	}
+}
+
+func TestHostCollectorRates(t *testing.T) {
+	collector, root := copyHostFixture(t)
+	if _, err := collector.Collect(); err != nil {
+		t.Fatal(err)
+	}
+
+	replaceHostFixture(t, root, "stat", "cpu  10000 500 3000 80000 1000", "cpu  10600 500 3200 81000 1200")
+	replaceHostFixture(t, root, "diskstats", "sda 50000 1000 4000000 20000 30000 2000 6000000 40000 0 50000", "sda 50100 1000 4020000 20000 30100 2000 6040000 40000 0 55000")
+	replaceHostFixture(t, root, "net/dev", "eth0: 800000000  600000    0    0    0     0          0      1000 200000000", "eth0: 810000000  600000    0    0    0     0          0      1000 205000000")
+	// 两次采样间隔按10秒计算
+	collector.prevAt = time.Now().Add(-10 * time.Second)
+	metrics, err := collector.Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !approxEqual(metrics.CPUUsed, 40) || !approxEqual(metrics.CPUIOWait, 10) {
+		t.Errorf("cpu used %.2f iowait %.2f, want 40 and 10", metrics.CPUUsed, metrics.CPUIOWait)
+	}
+	// 只保留整盘设备：sda1 为分区，loop0 为回环设备
+	if len(metrics.Disks) != 2 || metrics.Disks[0].Name != "nvme0n1" || metrics.Disks[1].Name != "sda" {
+		t.Fatalf("disks = %+v, want nvme0n1 and sda", metrics.Disks)
+	}
+	sda := metrics.Disks[1]
+	if !approxEqual(float64(sda.ReadBytes), 1024000) || !approxEqual(float64(sda.WriteBytes), 2048000) || !approxEqual(sda.IOUtil, 50) {
+		t.Errorf("sda = %+v, want 1024000 B/s read, 2048000 B/s write, 50%% busy", sda)
+	}
+	if nvme := metrics.Disks[0]; nvme.ReadBytes != 0 || nvme.WriteBytes != 0 || nvme.IOUtil != 0 {
+		t.Errorf("idle nvme0n1 = %+v", nvme)
+	}
+	if len(metrics.Networks) != 2 || metrics.Networks[0].Name != "docker0" || metrics.Networks[1].Name != "eth0" {
+		t.Fatalf("networks = %+v, want docker0 and eth0", metrics.Networks)
+	}
+	eth0 := metrics.Networks[1]
+	if !approxEqual(float64(eth0.RxBytes), 1000000) || !approxEqual(float64(eth0.TxBytes), 500000) {
+		t.Errorf("eth0 = %+v, want 1000000 B/s rx and 500000 B/s tx", eth0)
+	}
+}
+
+func TestHostCollectorOptionalFiles(t *testing.T) {
+	collector, root := copyHostFixture(t)
+	for _, name := range []string{"diskstats", "net/dev"} {
+		if err := os.Remove(filepath.Join(root, "proc", name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := collector.Collect(); err != nil {
+		t.Fatalf("missing diskstats and net/dev should not fail: %v", err)
+	}
+}
+
+func TestHostWatcherDisabledWithoutProc(t *testing.T) {
+	collector := &HostCollector{ProcPath: filepath.Join(t.TempDir(), "proc"), SysPath: t.TempDir()}
+	done := make(chan struct{})
+	called := false
+	go func() {
+		HostWatcher(collector, 10*time.Millisecond, func(models.HostMetrics) { called = true })
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("host watcher kept running without /proc")
+	}
+	if called {
+		t.Error("callback called without /proc")
+	}
+}
@@ -1,136 +1,256 @@
 package services
 
 import (
-	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"os/exec"
 	"strings"
 	"time"
 
+	"github.com/LanceLRQ/ollama-watchdog/configs"
 	"github.com/LanceLRQ/ollama-watchdog/models"
 	"github.com/LanceLRQ/ollama-watchdog/utils"
-	"github.com/dgraph-io/badger/v4"
 )
 
-func NvidiaSMIWatcher(callback func(models.NvidiaSMIResponse)) {
-	ticker := time.NewTicker(time.Duration(1 * float64(time.Second)))
-	defer ticker.Stop()
-
-	for range ticker.C {
-		gpuInfo, err := getGPUInfo()
+func init() {
+	RegisterGPUCollector("nvidia", func(cfg *configs.ServerConfigStruct) (GPUCollector, error) {
+		command, err := GetNvidiaSMICommand(cfg)
 		if err != nil {
-			fmt.Println("Error getting GPU info:", err)
-			continue
+			return nil, err
 		}
+		return &NvidiaSMICollector{Command: command}, nil
+	})
+}
 
-		gpuProcessesInfo, err := getGPUProcesses()
+// GetNvidiaSMICommand 获取调用 nvidia-smi 的命令
+//
+// 优先使用 nvidia_smi_command（可包含 docker exec / ssh 等前缀），否则使用 nvidia_smi_path
+func GetNvidiaSMICommand(cfg *configs.ServerConfigStruct) ([]string, error) {
+	if strings.TrimSpace(cfg.NvidiaSmiCommand) != "" {
+		command, err := utils.SplitCommandLine(cfg.NvidiaSmiCommand)
 		if err != nil {
-			fmt.Println("Error getting GPU processes info:", err)
-			continue
+			return nil, err
 		}
-		callback(models.NvidiaSMIResponse{
-			GPUInfo:      gpuInfo,
-			GPUProcesses: gpuProcessesInfo,
-			Timestamp:    time.Now().Unix(),
-		})
+		return command, nil
+	}
+	if cfg.NvidiaSmiPath != "" {
+		return []string{cfg.NvidiaSmiPath}, nil
 	}
+	return []string{"nvidia-smi"}, nil
 }
 
-func SaveSampleToDB(GPUSampleDB *badger.DB, nvidiaResp models.NvidiaSMIResponse) {
-	nvidiaResp.GPUProcesses = nil
-	jsonData, err := json.Marshal(nvidiaResp)
-	if err != nil {
-		fmt.Printf("JSON marshal error:%s\n", err.Error())
-		return
-	}
-	err = GPUSampleDB.Update(func(txn *badger.Txn) error {
-		e := badger.NewEntry([]byte(fmt.Sprintf("gpu:%d", time.Now().Unix())), jsonData).WithTTL(time.Hour)
-		err := txn.SetEntry(e)
-		if err != nil {
-			return fmt.Errorf("failed to record gpu sample: %w", err)
-		}
-		return nil
-	})
-	if err != nil {
-		fmt.Printf("%s\n", err.Error())
-	}
+// NvidiaSMICollector 基于 nvidia-smi 命令行的采集器
+type NvidiaSMICollector struct {
+	Command []string // nvidia-smi 命令及其前缀，如 ["ssh", "node", "nvidia-smi"]
 }
 
-func getGPUInfo() ([]models.GPUInfo, error) {
-	cmd := exec.Command(
-		"nvidia-smi",
-		"--query-gpu=pci.device_id,pci.bus_id,name,memory.total,memory.used,utilization.gpu,temperature.gpu,power.draw,power.limit",
-		"--format=csv,noheader,nounits",
-	)
+// exec 在配置的命令后追加参数并执行
+func (n *NvidiaSMICollector) exec(args ...string) *exec.Cmd {
+	return exec.Command(n.Command[0], append(n.Command[1:len(n.Command):len(n.Command)], args...)...)
+}
 
-	output, err := cmd.CombinedOutput()
+func (n *NvidiaSMICollector) Collect() (models.NvidiaSMIResponse, error) {
+	output, err := n.exec("-q", "-x").Output()
 	if err != nil {
-		return nil, fmt.Errorf("command failed: %v\nOutput: %s", err, output)
+		return models.NvidiaSMIResponse{}, fmt.Errorf("command failed: %v\nOutput: %s", err, output)
 	}
+	return ParseNvidiaSMIXML(output)
+}
 
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	var gpuInfos []models.GPUInfo
+// nvidiaSMILog nvidia-smi -q -x 的输出结构，只声明需要的字段
+//
+// 功耗字段在 530 之前的驱动中位于 power_readings，之后位于 gpu_power_readings，
+// 且 power_draw 可能被 instant_power_draw / average_power_draw 取代
+type nvidiaSMILog struct {
+	XMLName       xml.Name       `xml:"nvidia_smi_log"`
+	DriverVersion string         `xml:"driver_version"`
+	GPUs          []nvidiaSMIGPU `xml:"gpu"`
+}
 
-	for _, line := range lines {
-		fields := strings.Split(line, ", ")
-		if len(fields) != 9 {
-			fmt.Println(line)
-			return nil, fmt.Errorf("invalid output format")
-		}
+type nvidiaSMIGPU struct {
+	ID          string `xml:"id,attr"`
+	ProductName string `xml:"product_name"`
+	PCI         struct {
+		BusID    string `xml:"pci_bus_id"`
+		DeviceID string `xml:"pci_device_id"`
+		LinkInfo struct {
+			Gen   string `xml:"pcie_gen>current_link_gen"`
+			Width string `xml:"link_widths>current_link_width"`
+		} `xml:"pci_gpu_link_info"`
+		TxUtil string `xml:"tx_util"`
+		RxUtil string `xml:"rx_util"`
+	} `xml:"pci"`
+	FBMemoryUsage struct {
+		Total string `xml:"total"`
+		Used  string `xml:"used"`
+	} `xml:"fb_memory_usage"`
+	FanSpeed         string `xml:"fan_speed"`
+	PerformanceState string `xml:"performance_state"`
+	Utilization      struct {
+		GPU     string `xml:"gpu_util"`
+		Memory  string `xml:"memory_util"`
+		Encoder string `xml:"encoder_util"`
+		Decoder string `xml:"decoder_util"`
+	} `xml:"utilization"`
+	Temperature struct {
+		GPU string `xml:"gpu_temp"`
+	} `xml:"temperature"`
+	Clocks struct {
+		SM     string `xml:"sm_clock"`
+		Memory string `xml:"mem_clock"`
+	} `xml:"clocks"`
+	ClocksThrottleReasons nvidiaSMIReasons `xml:"clocks_throttle_reasons"`
+	ClocksEventReasons    nvidiaSMIReasons `xml:"clocks_event_reasons"`
+	ECCErrors             struct {
+		Volatile struct {
+			SingleBit struct {
+				Total string `xml:"total"`
+			} `xml:"single_bit"`
+			DoubleBit struct {
+				Total string `xml:"total"`
+			} `xml:"double_bit"`
+			SRAMCorrectable   string `xml:"sram_correctable"`
+			SRAMUncorrectable string `xml:"sram_uncorrectable"`
+			DRAMCorrectable   string `xml:"dram_correctable"`
+			DRAMUncorrectable string `xml:"dram_uncorrectable"`
+		} `xml:"volatile"`
+	} `xml:"ecc_errors"`
+	PowerReadings    nvidiaSMIPowerReadings `xml:"power_readings"`
+	GPUPowerReadings nvidiaSMIPowerReadings `xml:"gpu_power_readings"`
+	Processes        struct {
+		ProcessInfo []struct {
+			PID         string `xml:"pid"`
+			Type        string `xml:"type"`
+			ProcessName string `xml:"process_name"`
+			UsedMemory  string `xml:"used_memory"`
+		} `xml:"process_info"`
+	} `xml:"processes"`
+}
 
-		info := models.GPUInfo{
-			DeviceId:    strings.TrimSpace(fields[0]),
-			BusId:       strings.TrimSpace(fields[1]),
-			Name:        strings.TrimSpace(fields[2]),
-			MemoryTotal: utils.ParseUint(fields[3]),
-			MemoryUsed:  utils.ParseUint(fields[4]),
-			GPUUsed:     utils.ParseUint(fields[5]),
-			Temperature: utils.ParseUint(fields[6]),
-			PowerUsage:  utils.ParseFloat(fields[7]),
-			PowerLimit:  utils.ParseFloat(fields[8]),
-		}
+// nvidiaSMIReasons 降频原因列表，子元素形如 <clocks_event_reason_gpu_idle>Active</clocks_event_reason_gpu_idle>
+type nvidiaSMIReasons struct {
+	Reasons []struct {
+		XMLName xml.Name
+		Value   string `xml:",chardata"`
+	} `xml:",any"`
+}
 
-		gpuInfos = append(gpuInfos, info)
+// Active 返回当前生效的降频原因（去掉前缀后的名称）
+func (r nvidiaSMIReasons) Active() []string {
+	var active []string
+	for _, reason := range r.Reasons {
+		if strings.TrimSpace(reason.Value) != "Active" {
+			continue
+		}
+		name := reason.XMLName.Local
+		name = strings.TrimPrefix(name, "clocks_throttle_reason_")
+		name = strings.TrimPrefix(name, "clocks_event_reason_")
+		active = append(active, name)
 	}
-
-	return gpuInfos, nil
+	return active
 }
 
-func getGPUProcesses() ([]models.GPUProcess, error) {
-	cmd := exec.Command(
-		"nvidia-smi",
-		"--query-compute-apps=gpu_bus_id,pid,process_name,used_memory",
-		"--format=csv,noheader,nounits",
-	)
+type nvidiaSMIPowerReadings struct {
+	PowerDraw         string `xml:"power_draw"`
+	InstantPowerDraw  string `xml:"instant_power_draw"`
+	AveragePowerDraw  string `xml:"average_power_draw"`
+	PowerLimit        string `xml:"power_limit"`
+	CurrentPowerLimit string `xml:"current_power_limit"`
+}
 
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return nil, fmt.Errorf("command failed: %v\nOutput: %s", err, output)
+// ParseNvidiaSMIXML 解析 nvidia-smi -q -x 的输出，GPU信息与进程来自同一次调用
+func ParseNvidiaSMIXML(output []byte) (models.NvidiaSMIResponse, error) {
+	var log nvidiaSMILog
+	if err := xml.Unmarshal(output, &log); err != nil {
+		return models.NvidiaSMIResponse{}, fmt.Errorf("invalid nvidia-smi xml output: %w", err)
 	}
 
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	gpuInfos := make([]models.GPUInfo, 0, len(log.GPUs))
 	var gpuProcesses []models.GPUProcess
-
-	for _, line := range lines {
-		if strings.TrimSpace(line) == "" {
-			continue
+	for _, gpu := range log.GPUs {
+		busId := strings.TrimSpace(gpu.PCI.BusID)
+		if busId == "" {
+			busId = strings.TrimSpace(gpu.ID)
 		}
-		fields := strings.Split(line, ", ")
-		if len(fields) != 4 {
-			fmt.Println(line)
-			return nil, fmt.Errorf("invalid output format")
+		deviceId := strings.TrimSpace(gpu.PCI.DeviceID)
+		if deviceId != "" && !strings.HasPrefix(deviceId, "0x") {
+			// 与 --query-gpu=pci.device_id 的格式保持一致
+			deviceId = "0x" + deviceId
 		}
 
-		// 解析每一行数据并创建GPUProcess对象
-		info := models.GPUProcess{
-			BusId:      fields[0],
-			PID:        utils.ParseUint(fields[1]),
-			Name:       fields[2],
-			MemoryUsed: utils.ParseUint(fields[3]),
-		}
+		gpuInfos = append(gpuInfos, models.GPUInfo{
+			DeviceId:    deviceId,
+			BusId:       busId,
+			Name:        strings.TrimSpace(gpu.ProductName),
+			MemoryTotal: utils.ParseUint(gpu.FBMemoryUsage.Total),
+			MemoryUsed:  utils.ParseUint(gpu.FBMemoryUsage.Used),
+			GPUUsed:     utils.ParseUint(gpu.Utilization.GPU),
+			Temperature: utils.ParseUint(gpu.Temperature.GPU),
+			PowerUsage: utils.ParseFloat(firstAvailable(
+				gpu.GPUPowerReadings.PowerDraw,
+				gpu.GPUPowerReadings.InstantPowerDraw,
+				gpu.GPUPowerReadings.AveragePowerDraw,
+				gpu.PowerReadings.PowerDraw,
+				gpu.PowerReadings.InstantPowerDraw,
+				gpu.PowerReadings.AveragePowerDraw,
+			)),
+			PowerLimit: utils.ParseFloat(firstAvailable(
+				gpu.GPUPowerReadings.CurrentPowerLimit,
+				gpu.GPUPowerReadings.PowerLimit,
+				gpu.PowerReadings.PowerLimit,
+				gpu.PowerReadings.CurrentPowerLimit,
+			)),
+
+			ClockSM:          utils.ParseUint(gpu.Clocks.SM),
+			ClockMemory:      utils.ParseUint(gpu.Clocks.Memory),
+			FanSpeed:         utils.ParseUint(gpu.FanSpeed),
+			PerformanceState: firstAvailable(gpu.PerformanceState),
+			PCIeGen:          utils.ParseUint(gpu.PCI.LinkInfo.Gen),
+			PCIeWidth:        utils.ParseUint(gpu.PCI.LinkInfo.Width),
+			PCIeTxThroughput: utils.ParseUint(gpu.PCI.TxUtil),
+			PCIeRxThroughput: utils.ParseUint(gpu.PCI.RxUtil),
+			ThrottleReasons:  append(gpu.ClocksThrottleReasons.Active(), gpu.ClocksEventReasons.Active()...),
+			// 旧驱动按单/双比特统计，新驱动按 SRAM/DRAM 统计，不支持的字段为 N/A 解析为0
+			ECCCorrected: utils.ParseUint(gpu.ECCErrors.Volatile.SingleBit.Total) +
+				utils.ParseUint(gpu.ECCErrors.Volatile.SRAMCorrectable) +
+				utils.ParseUint(gpu.ECCErrors.Volatile.DRAMCorrectable),
+			ECCUncorrected: utils.ParseUint(gpu.ECCErrors.Volatile.DoubleBit.Total) +
+				utils.ParseUint(gpu.ECCErrors.Volatile.SRAMUncorrectable) +
+				utils.ParseUint(gpu.ECCErrors.Volatile.DRAMUncorrectable),
+			EncoderUsed:     utils.ParseUint(gpu.Utilization.Encoder),
+			DecoderUsed:     utils.ParseUint(gpu.Utilization.Decoder),
+			MemoryBandwidth: utils.ParseUint(gpu.Utilization.Memory),
+		})
 
-		gpuProcesses = append(gpuProcesses, info)
+		for _, proc := range gpu.Processes.ProcessInfo {
+			// 与 --query-compute-apps 一致，只保留计算进程（C、M+C、C+G），忽略 Xorg 等纯图形进程
+			if t := strings.TrimSpace(proc.Type); t != "" && !strings.Contains(t, "C") {
+				continue
+			}
+			gpuProcesses = append(gpuProcesses, models.GPUProcess{
+				BusId:      busId,
+				PID:        utils.ParseUint(proc.PID),
+				Name:       strings.TrimSpace(proc.ProcessName),
+				MemoryUsed: utils.ParseUint(proc.UsedMemory),
+			})
+		}
 	}
 
-	return gpuProcesses, nil
+	return models.NvidiaSMIResponse{
+		GPUInfo:      gpuInfos,
+		GPUProcesses: gpuProcesses,
+		Timestamp:    time.Now().Unix(),
+	}, nil
+}
+
+// firstAvailable 返回第一个有效值（nvidia-smi 对不支持的字段输出 N/A 或 [N/A]）
+func firstAvailable(values ...string) string {
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" || strings.Contains(v, "N/A") || strings.Contains(v, "Not Supported") {
+			continue
+		}
+		return v
+	}
+	return ""
 }
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os/exec"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LanceLRQ/ollama-watchdog/configs"
+	"github.com/LanceLRQ/ollama-watchdog/models"
+	"github.com/LanceLRQ/ollama-watchdog/utils"
+)
+
+func init() {
+	RegisterGPUCollector("nvidia-stream", func(cfg *configs.ServerConfigStruct) (GPUCollector, error) {
+		command, err := GetNvidiaSMICommand(cfg)
+		if err != nil {
+			return nil, err
+		}
+		collector := NewNvidiaSMIStreamCollector(command, configs.IntervalFromMs(cfg.GPUIntervalMs, time.Second))
+		collector.Start()
+		return collector, nil
+	})
+}
+
+// 流式查询的字段，名称放在最后以容忍其中包含的逗号
+const nvidiaStreamGPUQuery = "pci.device_id,pci.bus_id,memory.total,memory.used,utilization.gpu,temperature.gpu," +
+	"power.draw,power.limit,clocks.sm,clocks.mem,fan.speed,pstate,pcie.link.gen.current,pcie.link.width.current," +
+	"utilization.memory,ecc.errors.corrected.volatile.total,ecc.errors.uncorrected.volatile.total," +
+	"clocks_throttle_reasons.active,name"
+
+const nvidiaStreamProcessQuery = "gpu_bus_id,pid,used_memory,process_name"
+
+// clocks_throttle_reasons.active 位掩码对应的降频原因
+var nvidiaThrottleReasonBits = []struct {
+	Mask uint64
+	Name string
+}{
+	{0x1, "gpu_idle"},
+	{0x2, "applications_clocks_setting"},
+	{0x4, "sw_power_cap"},
+	{0x8, "hw_slowdown"},
+	{0x10, "sync_boost"},
+	{0x20, "sw_thermal_slowdown"},
+	{0x40, "hw_thermal_slowdown"},
+	{0x80, "hw_power_brake_slowdown"},
+	{0x100, "display_clock_setting"},
+}
+
+// NvidiaSMIStreamCollector 常驻一个 nvidia-smi -lms 子进程，解析其持续输出，避免每次采样都 fork
+//
+// GPU信息与进程列表分别由两个子进程输出，子进程退出后按指数退避重启
+type NvidiaSMIStreamCollector struct {
+	Command  []string
+	Interval time.Duration
+
+	mu        sync.RWMutex
+	gpus      []models.GPUInfo
+	gpusAt    time.Time
+	gpuParser NvidiaSMIStreamParser
+	processes map[streamProcessKey]streamProcess
+	backoff   time.Duration // 子进程退出后的初始重启间隔
+}
+
+// streamProcessKey 同一进程可能同时使用多块GPU，按总线ID和PID区分
+type streamProcessKey struct {
+	busId string
+	pid   uint64
+}
+
+type streamProcess struct {
+	process  models.GPUProcess
+	lastSeen time.Time
+}
+
+func NewNvidiaSMIStreamCollector(command []string, interval time.Duration) *NvidiaSMIStreamCollector {
+	if interval < 100*time.Millisecond {
+		interval = 100 * time.Millisecond
+	}
+	return &NvidiaSMIStreamCollector{
+		Command:   command,
+		Interval:  interval,
+		processes: map[streamProcessKey]streamProcess{},
+		backoff:   time.Second,
+	}
+}
+
+// Start 启动GPU与进程两个流式子进程
+func (n *NvidiaSMIStreamCollector) Start() {
+	interval := strconv.FormatInt(n.Interval.Milliseconds(), 10)
+	go n.supervise([]string{"--query-gpu=" + nvidiaStreamGPUQuery, "--format=csv,noheader,nounits", "-lms", interval}, n.handleGPULine, n.resetGPUParser)
+	go n.supervise([]string{"--query-compute-apps=" + nvidiaStreamProcessQuery, "--format=csv,noheader,nounits", "-lms", interval}, n.handleProcessLine, nil)
+}
+
+func (n *NvidiaSMIStreamCollector) Collect() (models.NvidiaSMIResponse, error) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	// 子进程重启期间数据不再更新，超过一定时间视为无效
+	if n.gpusAt.IsZero() || time.Since(n.gpusAt) > 5*n.Interval+time.Second {
+		return models.NvidiaSMIResponse{}, fmt.Errorf("no recent output from nvidia-smi stream")
+	}
+
+	gpus := make([]models.GPUInfo, len(n.gpus))
+	copy(gpus, n.gpus)
+	var processes []models.GPUProcess
+	for _, p := range n.processes {
+		if time.Since(p.lastSeen) <= 3*n.Interval {
+			processes = append(processes, p.process)
+		}
+	}
+	sort.Slice(processes, func(i, j int) bool {
+		if processes[i].BusId != processes[j].BusId {
+			return processes[i].BusId < processes[j].BusId
+		}
+		return processes[i].PID < processes[j].PID
+	})
+	return models.NvidiaSMIResponse{
+		GPUInfo:      gpus,
+		GPUProcesses: processes,
+		Timestamp:    time.Now().Unix(),
+	}, nil
+}
+
+// supervise 运行子进程并逐行处理输出，退出后按指数退避重启；reset 不为空时在每次启动前调用
+func (n *NvidiaSMIStreamCollector) supervise(args []string, handle func(string), reset func()) {
+	backoff := n.backoff
+	for {
+		if reset != nil {
+			reset()
+		}
+		started := time.Now()
+		err := n.runStream(args, handle)
+		// 运行足够久说明此前是正常的，重置退避时间
+		if time.Since(started) > time.Minute {
+			backoff = n.backoff
+		}
+		fmt.Printf("nvidia-smi stream exited: %v, restarting in %s\n", err, backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > 30*time.Second {
+			backoff = 30 * time.Second
+		}
+	}
+}
+
+func (n *NvidiaSMIStreamCollector) runStream(args []string, handle func(string)) error {
+	cmd := exec.Command(n.Command[0], append(n.Command[1:len(n.Command):len(n.Command)], args...)...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if err := ScanStreamLines(stdout, handle); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return err
+	}
+	return cmd.Wait()
+}
+
+// ScanStreamLines 逐行读取流式输出，不完整的行会等待后续数据补齐
+func ScanStreamLines(r io.Reader, handle func(string)) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		handle(line)
+	}
+	return scanner.Err()
+}
+
+// resetGPUParser 丢弃上一个子进程未输出完的一轮
+func (n *NvidiaSMIStreamCollector) resetGPUParser() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.gpuParser = NvidiaSMIStreamParser{}
+}
+
+func (n *NvidiaSMIStreamCollector) handleGPULine(line string) {
+	info, err := ParseNvidiaStreamGPULine(line)
+	if err != nil {
+		fmt.Println("Error parsing nvidia-smi stream:", err)
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if snapshot := n.gpuParser.Push(info); snapshot != nil {
+		n.gpus = snapshot
+		n.gpusAt = time.Now()
+	}
+}
+
+func (n *NvidiaSMIStreamCollector) handleProcessLine(line string) {
+	fields := strings.SplitN(line, ", ", 4)
+	if len(fields) != 4 {
+		fmt.Println("Error parsing nvidia-smi stream: invalid process line:", line)
+		return
+	}
+	proc := models.GPUProcess{
+		BusId:      strings.TrimSpace(fields[0]),
+		PID:        utils.ParseUint(fields[1]),
+		MemoryUsed: utils.ParseUint(fields[2]),
+		Name:       strings.TrimSpace(fields[3]),
+	}
+	now := time.Now()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.processes[streamProcessKey{busId: proc.BusId, pid: proc.PID}] = streamProcess{process: proc, lastSeen: now}
+	for key, p := range n.processes {
+		if now.Sub(p.lastSeen) > 3*n.Interval {
+			delete(n.processes, key)
+		}
+	}
+}
+
+// NvidiaSMIStreamParser 将逐行输出的GPU信息组装成完整的快照，快照按总线ID排序
+//
+// 每轮输出中每块GPU各占一行；当本轮已包含上一轮的全部GPU，或出现重复的总线ID时，视为一轮结束。
+// 每次出现重复的总线ID都会重新确定一轮包含哪些GPU，因此丢行、GPU数量增减后都能恢复
+type NvidiaSMIStreamParser struct {
+	pending []models.GPUInfo
+	round   map[string]bool // 上一轮的总线ID
+}
+
+// Push 追加一行数据，凑齐一轮时返回完整的快照
+func (p *NvidiaSMIStreamParser) Push(info models.GPUInfo) []models.GPUInfo {
+	var snapshot []models.GPUInfo
+	for _, pending := range p.pending {
+		if pending.BusId == info.BusId {
+			snapshot = p.pending
+			p.round = map[string]bool{}
+			for _, gpu := range snapshot {
+				p.round[gpu.BusId] = true
+			}
+			p.pending = nil
+			break
+		}
+	}
+	p.pending = append(p.pending, info)
+	// 本轮已凑齐时直接返回最新一轮
+	if len(p.pending) == len(p.round) && !slices.ContainsFunc(p.pending, func(gpu models.GPUInfo) bool { return !p.round[gpu.BusId] }) {
+		snapshot = p.pending
+		p.pending = nil
+	}
+	sort.Slice(snapshot, func(i, j int) bool { return snapshot[i].BusId < snapshot[j].BusId })
+	return snapshot
+}
+
+// ParseNvidiaStreamGPULine 解析一行 --query-gpu 输出，字段顺序见 nvidiaStreamGPUQuery
+func ParseNvidiaStreamGPULine(line string) (models.GPUInfo, error) {
+	fieldCount := strings.Count(nvidiaStreamGPUQuery, ",") + 1
+	fields := strings.SplitN(line, ", ", fieldCount)
+	if len(fields) != fieldCount {
+		return models.GPUInfo{}, fmt.Errorf("invalid output format: %s", line)
+	}
+	return models.GPUInfo{
+		DeviceId:         strings.TrimSpace(fields[0]),
+		BusId:            strings.TrimSpace(fields[1]),
+		MemoryTotal:      utils.ParseUint(fields[2]),
+		MemoryUsed:       utils.ParseUint(fields[3]),
+		GPUUsed:          utils.ParseUint(fields[4]),
+		Temperature:      utils.ParseUint(fields[5]),
+		PowerUsage:       utils.ParseFloat(fields[6]),
+		PowerLimit:       utils.ParseFloat(fields[7]),
+		ClockSM:          utils.ParseUint(fields[8]),
+		ClockMemory:      utils.ParseUint(fields[9]),
+		FanSpeed:         utils.ParseUint(fields[10]),
+		PerformanceState: firstAvailable(fields[11]),
+		PCIeGen:          utils.ParseUint(fields[12]),
+		PCIeWidth:        utils.ParseUint(fields[13]),
+		MemoryBandwidth:  utils.ParseUint(fields[14]),
+		ECCCorrected:     utils.ParseUint(fields[15]),
+		ECCUncorrected:   utils.ParseUint(fields[16]),
+		ThrottleReasons:  nvidiaThrottleReasons(fields[17]),
+		Name:             strings.TrimSpace(fields[18]),
+	}, nil
+}
+
+func nvidiaThrottleReasons(value string) []string {
+	mask, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimSpace(value), "0x"), 16, 64)
+	if err != nil {
+		return nil
+	}
+	var reasons []string
+	for _, bit := range nvidiaThrottleReasonBits {
+		if mask&bit.Mask != 0 {
+			reasons = append(reasons, bit.Name)
+		}
+	}
+	return reasons
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LanceLRQ/ollama-watchdog/models"
+)
+
+// 模拟 nvidia-smi -lms：每次启动在 runs 文件中追加一行；GPU 行分两次写出以模拟不完整的行，
+// 第一次启动输出几轮后异常退出，之后的启动利用率随启动次数变化；为避免测试结束后残留，循环次数有限
+const fakeNvidiaStreamScript = `#!/bin/sh
+dir=$(dirname "$0")
+case "$1" in
+--query-gpu=*)
+	echo x >> "$dir/gpu_runs"
+	runs=$(wc -l < "$dir/gpu_runs")
+	util=$((runs * 10))
+	i=0
+	while [ $i -lt 60 ]; do
+		printf '0x268410DE, 00000000:01:00.0, 24564, 1000, %d, 40, 100.00, ' $util
+		sleep 0.02
+		printf '450.00, 2520, 10251, 30, P2, 4, 16, 5, 0, 0, 0x0000000000000004, NVIDIA GeForce RTX 4090, Founders\n'
+		printf '0x268410DE, 00000000:02:00.0, 24564, 2000, %d, 41, 90.00, 450.00, 2520, 10251, 30, P2, 4, 16, 5, 0, 0, 0x1, NVIDIA GeForce RTX 4090\n' $util
+		sleep 0.03
+		i=$((i + 1))
+		if [ $runs -eq 1 ] && [ $i -ge 3 ]; then
+			exit 1
+		fi
+	done
+	;;
+--query-compute-apps=*)
+	i=0
+	while [ $i -lt 60 ]; do
+		echo '00000000:01:00.0, 4242, 1000, /usr/bin/ollama'
+		echo '00000000:02:00.0, 4242, 2000, /usr/bin/ollama'
+		sleep 0.05
+		i=$((i + 1))
+	done
+	;;
+esac
+`
+
+func TestNvidiaSMIStreamCollector(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "nvidia-smi")
+	if err := os.WriteFile(script, []byte(fakeNvidiaStreamScript), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	collector := NewNvidiaSMIStreamCollector([]string{script}, 100*time.Millisecond)
+	collector.backoff = 50 * time.Millisecond
+	collector.Start()
+
+	// 等待子进程重启后的输出
+	var resp models.NvidiaSMIResponse
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var err error
+		resp, err = collector.Collect()
+		if err == nil && len(resp.GPUInfo) == 2 && resp.GPUInfo[0].GPUUsed == 20 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("collector did not recover after child restart: %+v, %v", resp, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	runs, _ := os.ReadFile(filepath.Join(dir, "gpu_runs"))
+	if n := strings.Count(string(runs), "\n"); n < 2 {
+		t.Errorf("child started %d times, want at least 2", n)
+	}
+
+	gpu := resp.GPUInfo[0]
+	if gpu.BusId != "00000000:01:00.0" || gpu.MemoryUsed != 1000 || gpu.PowerLimit != 450 || gpu.Name != "NVIDIA GeForce RTX 4090, Founders" {
+		t.Errorf("unexpected GPU 0: %+v", gpu)
+	}
+	if !slices.Equal(gpu.ThrottleReasons, []string{"sw_power_cap"}) {
+		t.Errorf("throttle reasons = %v", gpu.ThrottleReasons)
+	}
+
+	// 同一 PID 在两块GPU上各占一条记录
+	want := []models.GPUProcess{
+		{BusId: "00000000:01:00.0", PID: 4242, Name: "/usr/bin/ollama", MemoryUsed: 1000},
+		{BusId: "00000000:02:00.0", PID: 4242, Name: "/usr/bin/ollama", MemoryUsed: 2000},
+	}
+	if !slices.Equal(resp.GPUProcesses, want) {
+		t.Errorf("processes:\n got %+v\nwant %+v", resp.GPUProcesses, want)
+	}
+}
+
+func TestScanStreamLinesPartial(t *testing.T) {
+	r, w := io.Pipe()
+	go func() {
+		for _, chunk := range []string{"first, li", "ne\nsecond", " line\n\n", "third"} {
+			w.Write([]byte(chunk))
+			time.Sleep(5 * time.Millisecond)
+		}
+		w.Close()
+	}()
+	var lines []string
+	if err := ScanStreamLines(r, func(line string) { lines = append(lines, line) }); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"first, line", "second line", "third"}; !slices.Equal(lines, want) {
+		t.Errorf("lines = %q, want %q", lines, want)
+	}
+}
+
+func TestNvidiaSMIStreamParser(t *testing.T) {
+	var p NvidiaSMIStreamParser
+	push := func(busId string) []models.GPUInfo { return p.Push(models.GPUInfo{BusId: busId}) }
+
+	if push("a") != nil || push("b") != nil {
+		t.Fatal("first round must wait for a repeated bus id")
+	}
+	if got := push("a"); len(got) != 2 {
+		t.Fatalf("repeated bus id should flush previous round, got %+v", got)
+	}
+	if got := push("b"); len(got) != 2 {
+		t.Fatalf("round should complete once expected count is reached, got %+v", got)
+	}
+	// GPU 数量减少
+	push("a")
+	if got := push("a"); len(got) != 1 {
+		t.Fatalf("got %+v, want single GPU round", got)
+	}
+	// GPU 数量恢复后重新凑齐两块GPU的快照
+	var last []models.GPUInfo
+	for _, busId := range []string{"a", "b", "a", "b", "a", "b"} {
+		if got := push(busId); got != nil {
+			last = got
+		}
+	}
+	if len(last) != 2 || last[0].BusId != "a" || last[1].BusId != "b" {
+		t.Fatalf("got %+v after GPU count grew back, want a and b", last)
+	}
+	// 之后每两行凑齐一轮
+	rounds := 0
+	for _, busId := range []string{"a", "b", "a", "b"} {
+		if got := push(busId); got != nil {
+			if len(got) != 2 {
+				t.Fatalf("got %+v, want two GPU round", got)
+			}
+			rounds++
+		}
+	}
+	if rounds != 2 {
+		t.Fatalf("got %d rounds from 4 lines, want 2", rounds)
+	}
+}
+
+func TestNvidiaSMIStreamParserSkippedLine(t *testing.T) {
+	var p NvidiaSMIStreamParser
+	var snapshots [][]models.GPUInfo
+	// 第二轮中 b 行解析失败被跳过
+	for _, busId := range []string{"a", "b", "c", "a", "b", "c", "a", "c", "a", "b", "c", "a", "b", "c", "a"} {
+		if got := p.Push(models.GPUInfo{BusId: busId}); got != nil {
+			snapshots = append(snapshots, got)
+		}
+	}
+	last := snapshots[len(snapshots)-1]
+	if len(last) != 3 {
+		t.Fatalf("got %+v, want three GPU round after recovery", last)
+	}
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/LanceLRQ/ollama-watchdog/models"
+)
+
+func TestParseNvidiaSMIXML(t *testing.T) {
+	tests := []struct {
+		fixture   string
+		gpus      []models.GPUInfo
+		processes []models.GPUProcess
+	}{
+		{
+			// 530 之前：power_readings、clocks_throttle_reasons、单/双比特 ECC，风扇为 N/A
+			fixture: "driver_470.xml",
+			gpus: []models.GPUInfo{{
+				DeviceId: "0x1DB610DE", BusId: "00000000:3B:00.0", Name: "Tesla V100-PCIE-32GB",
+				MemoryTotal: 32510, MemoryUsed: 20480, GPUUsed: 87, Temperature: 71,
+				PowerUsage: 243.52, PowerLimit: 250,
+				ClockSM: 1380, ClockMemory: 877, FanSpeed: 0, PerformanceState: "P0",
+				PCIeGen: 3, PCIeWidth: 16, PCIeTxThroughput: 12000, PCIeRxThroughput: 48000,
+				ThrottleReasons: []string{"sw_power_cap"},
+				ECCCorrected:    2, MemoryBandwidth: 54,
+			}},
+			processes: []models.GPUProcess{
+				{BusId: "00000000:3B:00.0", PID: 2817, Name: "/usr/bin/python3 serve.py --workers 2, --port 8000", MemoryUsed: 20476},
+			},
+		},
+		{
+			// 530 之后：gpu_power_readings 中的 power_draw / current_power_limit，ECC 不支持时为 N/A
+			fixture: "driver_535.xml",
+			gpus: []models.GPUInfo{
+				{
+					DeviceId: "0x268410DE", BusId: "00000000:01:00.0", Name: "NVIDIA GeForce RTX 4090",
+					MemoryTotal: 24564, MemoryUsed: 18000, GPUUsed: 100, Temperature: 83,
+					PowerUsage: 441.27, PowerLimit: 450,
+					ClockSM: 2520, ClockMemory: 10251, FanSpeed: 45, PerformanceState: "P2",
+					PCIeGen: 4, PCIeWidth: 16, PCIeTxThroughput: 350, PCIeRxThroughput: 900,
+					ThrottleReasons: []string{"sw_thermal_slowdown"},
+					DecoderUsed:     3, MemoryBandwidth: 78,
+				},
+				{
+					DeviceId: "0x268410DE", BusId: "00000000:02:00.0", Name: "NVIDIA GeForce RTX 4090",
+					MemoryTotal: 24564, MemoryUsed: 1, Temperature: 34,
+					PowerUsage: 12.03, PowerLimit: 450,
+					ClockSM: 210, ClockMemory: 405, FanSpeed: 30, PerformanceState: "P8",
+					PCIeGen: 1, PCIeWidth: 8,
+					ThrottleReasons: []string{"gpu_idle"},
+				},
+			},
+			processes: []models.GPUProcess{
+				{BusId: "00000000:01:00.0", PID: 5120, Name: "/usr/local/bin/ollama", MemoryUsed: 17990},
+			},
+		},
+		{
+			// 545 之后：instant/average_power_draw、clocks_event_reasons、SRAM/DRAM ECC，PCIe 吞吐与编解码器为 N/A
+			fixture: "driver_550.xml",
+			gpus: []models.GPUInfo{{
+				DeviceId: "0x20B210DE", BusId: "00000000:17:00.0", Name: "NVIDIA A100-SXM4-80GB",
+				MemoryTotal: 81920, MemoryUsed: 40960, GPUUsed: 64, Temperature: 58,
+				PowerUsage: 318.77, PowerLimit: 400,
+				ClockSM: 1410, ClockMemory: 1593, PerformanceState: "P0",
+				PCIeGen: 4, PCIeWidth: 16,
+				ThrottleReasons: []string{"sw_power_cap", "hw_thermal_slowdown"},
+				ECCCorrected:    5, ECCUncorrected: 1, MemoryBandwidth: 31,
+			}},
+			processes: []models.GPUProcess{
+				{BusId: "00000000:17:00.0", PID: 7001, Name: "ollama_llama_server", MemoryUsed: 40950},
+				{BusId: "00000000:17:00.0", PID: 7340, Name: "/opt/tritonserver/bin/tritonserver", MemoryUsed: 1024},
+				{BusId: "00000000:17:00.0", PID: 7412, Name: "/usr/bin/blender", MemoryUsed: 512},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			output, err := os.ReadFile(filepath.Join("testdata", "nvidia", tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := ParseNvidiaSMIXML(output)
+			if err != nil {
+				t.Fatalf("ParseNvidiaSMIXML: %v", err)
+			}
+			if len(resp.GPUInfo) != len(tt.gpus) {
+				t.Fatalf("got %d GPUs, want %d", len(resp.GPUInfo), len(tt.gpus))
+			}
+			for i, want := range tt.gpus {
+				if got := resp.GPUInfo[i]; !reflect.DeepEqual(got, want) {
+					t.Errorf("GPU %d:\n got %+v\nwant %+v", i, got, want)
+				}
+			}
+			if !slices.Equal(resp.GPUProcesses, tt.processes) {
+				t.Errorf("processes:\n got %+v\nwant %+v", resp.GPUProcesses, tt.processes)
+			}
+		})
+	}
+}
+
+func TestParseNvidiaSMIXMLInvalid(t *testing.T) {
+	if _, err := ParseNvidiaSMIXML([]byte("NVIDIA-SMI has failed because it couldn't communicate with the NVIDIA driver.")); err == nil {
+		t.Error("expected error for non-XML output")
+	}
+}
@@ -1,74 +1,99 @@
 package services
 
 import (
-	"encoding/json"
+	"context"
+	"sync"
 	"time"
 
 	"github.com/LanceLRQ/ollama-watchdog/configs"
-	"github.com/gofiber/fiber/v2"
+	"github.com/LanceLRQ/ollama-watchdog/models"
+	"github.com/LanceLRQ/ollama-watchdog/ollama"
 )
 
-func OllamaPSWatcher(cfg *configs.ServerConfigStruct, callback func(fiber.Map)) {
-	ticker := time.NewTicker(time.Duration(time.Second))
+func OllamaPSWatcher(cfg *configs.ServerConfigStruct, tracker *OllamaHealthTracker, callback func(models.OllamaPSResponse)) {
+	ticker := time.NewTicker(configs.IntervalFromMs(cfg.OllamaIntervalMs, time.Second))
 	defer ticker.Stop()
 
 	for range ticker.C {
-		callback(GetOllamaPS(cfg))
+		callback(GetOllamaPS(cfg, tracker))
 	}
 }
 
-func ollamaAPIAgent(host string) (fiber.Map, error) {
-	agent := fiber.Get(host + "/api/ps")
-	agent.Timeout(time.Second)
-	_, body, errs := agent.Bytes()
-	if len(errs) > 0 {
-		return nil, errs[0]
-	}
-
-	var resp fiber.Map
-	err := json.Unmarshal(body, &resp)
-	if err != nil {
-		return nil, err
-	}
-	return resp, nil
-}
-
 // Get Ollama Process Status
-func GetOllamaPS(cfg *configs.ServerConfigStruct) fiber.Map {
+//
+// 各实例并发请求（并发数受 ollama_concurrency 限制），每个实例有独立的超时，
+// 个别实例无响应不会拖慢整体；探测结果记录到 tracker 中
+func GetOllamaPS(cfg *configs.ServerConfigStruct, tracker *OllamaHealthTracker) models.OllamaPSResponse {
 	if len(cfg.OllamaListens) > 0 {
-		responses := make([]fiber.Map, len(cfg.OllamaListens))
-		for i, host := range cfg.OllamaListens {
-			resp, err := ollamaAPIAgent(host)
+		responses := make([]models.OllamaInstancePS, len(cfg.OllamaListens))
+		timeout := configs.IntervalFromMs(cfg.OllamaTimeoutMs, time.Second)
+		forEachOllamaInstance(cfg, func(i int, host string) {
 			serviceName := ""
 			if len(cfg.OllamaServices) > i {
 				serviceName = cfg.OllamaServices[i]
 			}
-			if err != nil {
-				responses[i] = fiber.Map{
-					"server":       host,
-					"service_name": serviceName,
-					"status":       false,
-					"data":         nil,
-				}
-				continue
-			}
-			responses[i] = fiber.Map{
-				"server":       host,
-				"service_name": serviceName,
-				"status":       true,
-				"data":         resp,
-			}
-		}
-		return fiber.Map{
-			"status":  true,
-			"data":    responses,
-			"message": "",
+			responses[i] = getOllamaInstancePS(tracker, host, serviceName, timeout)
+		})
+
+		return models.OllamaPSResponse{
+			Status:  true,
+			Data:    responses,
+			Message: "",
 		}
 	}
-	return fiber.Map{
-		"status":  false,
-		"data":    nil,
-		"message": "没有配置ollama监听地址",
+	return models.OllamaPSResponse{
+		Status:  false,
+		Data:    nil,
+		Message: "没有配置ollama监听地址",
+	}
+
+}
+
+// forEachOllamaInstance 并发地对每个 Ollama 实例执行 fn，并发数受 ollama_concurrency 限制，全部完成后返回
+func forEachOllamaInstance(cfg *configs.ServerConfigStruct, fn func(i int, host string)) {
+	concurrency := cfg.OllamaConcurrency
+	if concurrency <= 0 {
+		concurrency = len(cfg.OllamaListens)
+	}
+	semaphore := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	for i, host := range cfg.OllamaListens {
+		wg.Add(1)
+		go func(i int, host string) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			fn(i, host)
+		}(i, host)
+	}
+	wg.Wait()
+}
+
+func getOllamaInstancePS(tracker *OllamaHealthTracker, host string, serviceName string, timeout time.Duration) models.OllamaInstancePS {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	client := ollama.NewClient(host)
+	start := time.Now()
+	resp, err := client.PS(ctx)
+	latency := time.Since(start)
+	result := models.OllamaInstancePS{
+		Server:      host,
+		ServiceName: serviceName,
+		Status:      err == nil,
+		Data:        resp,
+		LatencyMs:   latency.Milliseconds(),
+	}
+	if err != nil {
+		result.Error = err.Error()
+		result.Unreachable = ollama.IsUnreachable(err)
+	} else {
+		refreshOllamaVersion(tracker, host, client, timeout)
 	}
 
+	health := tracker.Observe(host, latency, err)
+	result.LastSuccess = health.LastHealthy
+	result.Health = &health
+	return result
 }
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LanceLRQ/ollama-watchdog/configs"
+	"github.com/LanceLRQ/ollama-watchdog/models"
+	"github.com/LanceLRQ/ollama-watchdog/ollama"
+)
+
+// 匹配模型文件路径中的 blob 摘要，如 /usr/share/ollama/.ollama/models/blobs/sha256-<hex>
+var ollamaBlobRegexp = regexp.MustCompile(`sha256[-:]([0-9a-f]{64})`)
+
+// OllamaModelAttributor 将GPU上的 ollama runner 进程与 /api/ps 中已加载的模型关联起来
+//
+// runner 的命令行中带有 --model <blob路径>，模型的 blob 通过 /api/show 返回的 modelfile 获得；
+// 多个实例加载同一个 blob 时，再用 runner 的父进程与实例对应的 systemd 服务主进程区分。
+// /api/show 在后台协程中请求，不阻塞轮询；失败后按指数退避重试
+type OllamaModelAttributor struct {
+	cfg *configs.ServerConfigStruct
+
+	mu          sync.RWMutex
+	loaded      []ollamaLoadedModel
+	blobs       map[string]*ollamaBlobLookup // server|model|digest -> blob查询状态
+	servicePIDs map[string]uint64            // server -> 服务主进程ID
+	pidsAt      time.Time
+}
+
+type ollamaBlobLookup struct {
+	blob      string
+	resolved  bool
+	resolving bool
+	retryAt   time.Time
+	backoff   time.Duration
+}
+
+// blob 查询失败后的重试间隔
+const (
+	ollamaBlobMinBackoff = 5 * time.Second
+	ollamaBlobMaxBackoff = 5 * time.Minute
+)
+
+type ollamaLoadedModel struct {
+	Server string
+	Name   string
+	Blob   string
+}
+
+func NewOllamaModelAttributor(cfg *configs.ServerConfigStruct) *OllamaModelAttributor {
+	return &OllamaModelAttributor{
+		cfg:         cfg,
+		blobs:       map[string]*ollamaBlobLookup{},
+		servicePIDs: map[string]uint64{},
+	}
+}
+
+// UpdateOllamaPS 根据最新的 /api/ps 结果刷新已加载模型及其 blob，请求失败的实例沿用上次的结果
+func (a *OllamaModelAttributor) UpdateOllamaPS(response models.OllamaPSResponse) {
+	a.mu.RLock()
+	previous := a.loaded
+	a.mu.RUnlock()
+
+	var loaded []ollamaLoadedModel
+	for _, instance := range response.Data {
+		if instance.Data == nil {
+			// /api/ps 失败时保留上次的关联，否则卡死检测找不到实例使用的GPU
+			for _, model := range previous {
+				if model.Server == instance.Server {
+					loaded = append(loaded, model)
+				}
+			}
+			continue
+		}
+		for _, model := range instance.Data.Models {
+			// blob 尚未获取到的模型暂不参与关联
+			if blob, ok := a.lookupBlob(instance.Server, model.Name, model.Digest); ok {
+				loaded = append(loaded, ollamaLoadedModel{Server: instance.Server, Name: model.Name, Blob: blob})
+			}
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.loaded = loaded
+	// 服务主进程变化不频繁，定期刷新即可
+	if time.Since(a.pidsAt) > 30*time.Second {
+		a.servicePIDs = map[string]uint64{}
+		for i, server := range a.cfg.OllamaListens {
+			if i < len(a.cfg.OllamaServices) && a.cfg.OllamaServices[i] != "" {
+				if pid := serviceMainPID(a.cfg.OllamaServices[i]); pid > 0 {
+					a.servicePIDs[server] = pid
+				}
+			}
+		}
+		a.pidsAt = time.Now()
+	}
+}
+
+// lookupBlob 返回已获取的 blob；尚未获取且不在退避期内时启动后台查询
+func (a *OllamaModelAttributor) lookupBlob(server string, model string, digest string) (string, bool) {
+	key := server + "|" + model + "|" + digest
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	lookup, ok := a.blobs[key]
+	if !ok {
+		lookup = &ollamaBlobLookup{}
+		a.blobs[key] = lookup
+	}
+	if lookup.resolved {
+		return lookup.blob, true
+	}
+	if !lookup.resolving && !time.Now().Before(lookup.retryAt) {
+		lookup.resolving = true
+		go a.resolveBlob(lookup, server, model)
+	}
+	return "", false
+}
+
+func (a *OllamaModelAttributor) resolveBlob(lookup *ollamaBlobLookup, server string, model string) {
+	blob, err := ollamaModelBlob(server, model)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	lookup.resolving = false
+	if err != nil {
+		lookup.backoff = min(max(lookup.backoff*2, ollamaBlobMinBackoff), ollamaBlobMaxBackoff)
+		lookup.retryAt = time.Now().Add(lookup.backoff)
+		fmt.Printf("Error resolving blob of %s on %s: %v, retrying in %s\n", model, server, err, lookup.backoff)
+		return
+	}
+	lookup.blob = blob
+	lookup.resolved = true
+}
+
+// Attribute 计算每个已加载模型在各GPU上的显存占用
+func (a *OllamaModelAttributor) Attribute(processes []models.GPUProcess) []models.OllamaModelUsage {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var usages []models.OllamaModelUsage
+	for _, proc := range processes {
+		blob := OllamaRunnerBlob(proc.Cmdline)
+		if blob == "" {
+			continue
+		}
+		var candidates []ollamaLoadedModel
+		for _, model := range a.loaded {
+			if model.Blob == blob {
+				candidates = append(candidates, model)
+			}
+		}
+		if len(candidates) > 1 {
+			var byParent []ollamaLoadedModel
+			for _, model := range candidates {
+				if pid, ok := a.servicePIDs[model.Server]; ok && pid == proc.PPID {
+					byParent = append(byParent, model)
+				}
+			}
+			candidates = byParent
+		}
+		// 同一实例下多个别名指向同一个 blob 时取第一个；无法确定实例时不做关联
+		if len(candidates) == 0 || candidates[0].Server != candidates[len(candidates)-1].Server {
+			continue
+		}
+		usages = append(usages, models.OllamaModelUsage{
+			Server:     candidates[0].Server,
+			Model:      candidates[0].Name,
+			PID:        proc.PID,
+			BusId:      proc.BusId,
+			MemoryUsed: proc.MemoryUsed,
+		})
+	}
+	return usages
+}
+
+// OllamaRunnerBlob 从 ollama runner（或旧版 ollama_llama_server）的命令行中解析 --model 对应的 blob 摘要
+func OllamaRunnerBlob(cmdline string) string {
+	if !strings.Contains(cmdline, "ollama") {
+		return ""
+	}
+	args := strings.Fields(cmdline)
+	for i, arg := range args {
+		var path string
+		if arg == "--model" && i+1 < len(args) {
+			path = args[i+1]
+		} else if value, ok := strings.CutPrefix(arg, "--model="); ok {
+			path = value
+		}
+		if m := ollamaBlobRegexp.FindStringSubmatch(path); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// ollamaModelBlob 通过 /api/show 返回的 modelfile 获取模型权重的 blob 摘要
+func ollamaModelBlob(host string, model string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	resp, err := ollama.NewClient(host).Show(ctx, &ollama.ShowRequest{Model: model})
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(resp.Modelfile, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "FROM ") {
+			continue
+		}
+		if m := ollamaBlobRegexp.FindStringSubmatch(line); m != nil {
+			return m[1], nil
+		}
+	}
+	return "", nil
+}
+
+// serviceMainPID 获取 systemd 服务的主进程ID
+func serviceMainPID(serviceName string) uint64 {
+	output, err := exec.Command("systemctl", "show", "-p", "MainPID", "--value", serviceName).Output()
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.ParseUint(strings.TrimSpace(string(output)), 10, 64)
+	return pid
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/LanceLRQ/ollama-watchdog/configs"
+	"github.com/LanceLRQ/ollama-watchdog/models"
+	"github.com/LanceLRQ/ollama-watchdog/ollama"
+)
+
+// waitForOllamaBlobs 反复轮询直到后台获取到 want 个模型的 blob
+func waitForOllamaBlobs(t *testing.T, attributor *OllamaModelAttributor, response models.OllamaPSResponse, want int) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		attributor.UpdateOllamaPS(response)
+		attributor.mu.RLock()
+		loaded := len(attributor.loaded)
+		attributor.mu.RUnlock()
+		if loaded == want {
+			return
+		}
+	}
+	t.Fatalf("blobs of %d models not resolved", want)
+}
+
+func TestOllamaRunnerBlob(t *testing.T) {
+	blob := strings.Repeat("0123456789abcdef", 4)
+	tests := []struct {
+		name    string
+		cmdline string
+		want    string
+	}{
+		{"runner", "/usr/local/bin/ollama runner --model /usr/share/ollama/.ollama/models/blobs/sha256-" + blob + " --ctx-size 8192 --port 38127", blob},
+		{"equals form", "/usr/bin/ollama runner --model=/root/.ollama/models/blobs/sha256-" + blob + " --port 38127", blob},
+		{"legacy server", "/tmp/ollama123/runners/cuda_v12/ollama_llama_server --model /home/ai/.ollama/models/blobs/sha256:" + blob + " --ctx-size 2048", blob},
+		{"not ollama", "/usr/bin/python3 train.py --model /data/blobs/sha256-" + blob, ""},
+		{"ollama serve", "/usr/local/bin/ollama serve", ""},
+		{"model without blob", "/usr/local/bin/ollama runner --model /models/llama.gguf", ""},
+		{"missing value", "/usr/local/bin/ollama runner --model", ""},
+		{"short digest", "/usr/local/bin/ollama runner --model /blobs/sha256-abc123", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := OllamaRunnerBlob(tt.cmdline); got != tt.want {
+				t.Errorf("OllamaRunnerBlob(%q) = %q, want %q", tt.cmdline, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOllamaModelAttributorAttribute(t *testing.T) {
+	blobA := strings.Repeat("a", 64)
+	blobB := strings.Repeat("b", 64)
+	newInstance := func(blobs map[string]string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var req struct {
+				Model string `json:"model"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			fmt.Fprintf(w, `{"modelfile":"# Modelfile\nFROM /var/lib/ollama/blobs/sha256-%s\nTEMPLATE {{ .Prompt }}\n"}`, blobs[req.Model])
+		}))
+	}
+	first := newInstance(map[string]string{"llama3:8b": blobA, "qwen2.5:7b": blobB})
+	defer first.Close()
+	second := newInstance(map[string]string{"llama3:8b": blobA})
+	defer second.Close()
+
+	cfg := configs.GetDefaultServerConfig()
+	attributor := NewOllamaModelAttributor(&cfg)
+	response := models.OllamaPSResponse{Data: []models.OllamaInstancePS{
+		{Server: first.URL, Data: &ollama.PSResponse{Models: []ollama.ProcessModel{{Name: "llama3:8b", Digest: "1"}, {Name: "qwen2.5:7b", Digest: "2"}}}},
+		{Server: second.URL, Data: &ollama.PSResponse{Models: []ollama.ProcessModel{{Name: "llama3:8b", Digest: "1"}}}},
+	}}
+	waitForOllamaBlobs(t, attributor, response, 3)
+	// 两个实例都加载了 blobA，按 runner 的父进程区分
+	attributor.mu.Lock()
+	attributor.servicePIDs = map[string]uint64{first.URL: 100, second.URL: 200}
+	attributor.pidsAt = time.Now()
+	attributor.mu.Unlock()
+
+	runner := func(pid uint64, ppid uint64, busId string, blob string) models.GPUProcess {
+		return models.GPUProcess{
+			BusId:      busId,
+			PID:        pid,
+			PPID:       ppid,
+			MemoryUsed: 1000,
+			Cmdline:    "/usr/local/bin/ollama runner --model /var/lib/ollama/blobs/sha256-" + blob + " --port 40000",
+		}
+	}
+	usages := attributor.Attribute([]models.GPUProcess{
+		runner(11, 100, "gpu0", blobA),
+		runner(11, 100, "gpu1", blobA),
+		runner(12, 100, "gpu0", blobB),
+		runner(21, 200, "gpu1", blobA),
+		runner(31, 999, "gpu1", blobA), // 父进程不属于任何实例
+		{BusId: "gpu0", PID: 41, Cmdline: "/usr/bin/python3 train.py"},
+	})
+	want := []models.OllamaModelUsage{
+		{Server: first.URL, Model: "llama3:8b", PID: 11, BusId: "gpu0", MemoryUsed: 1000},
+		{Server: first.URL, Model: "llama3:8b", PID: 11, BusId: "gpu1", MemoryUsed: 1000},
+		{Server: first.URL, Model: "qwen2.5:7b", PID: 12, BusId: "gpu0", MemoryUsed: 1000},
+		{Server: second.URL, Model: "llama3:8b", PID: 21, BusId: "gpu1", MemoryUsed: 1000},
+	}
+	if len(usages) != len(want) {
+		t.Fatalf("usages = %+v, want %+v", usages, want)
+	}
+	for i := range want {
+		if usages[i] != want[i] {
+			t.Errorf("usage %d = %+v, want %+v", i, usages[i], want[i])
+		}
+	}
+}
+
+func TestOllamaModelAttributorBlobBackoff(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(300 * time.Millisecond)
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	cfg := configs.GetDefaultServerConfig()
+	attributor := NewOllamaModelAttributor(&cfg)
+	response := models.OllamaPSResponse{Data: []models.OllamaInstancePS{
+		{Server: srv.URL, Data: &ollama.PSResponse{Models: []ollama.ProcessModel{{Name: "llama3:8b", Digest: "1"}}}},
+	}}
+
+	// 查询在后台进行，轮询不等待 /api/show
+	start := time.Now()
+	for range 20 {
+		attributor.UpdateOllamaPS(response)
+	}
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("UpdateOllamaPS blocked for %s", elapsed)
+	}
+
+	// 失败后在退避期内不再重试
+	time.Sleep(500 * time.Millisecond)
+	for range 20 {
+		attributor.UpdateOllamaPS(response)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := calls.Load(); n != 1 {
+		t.Errorf("/api/show called %d times, want 1", n)
+	}
+	attributor.mu.RLock()
+	lookup := *attributor.blobs[srv.URL+"|llama3:8b|1"]
+	attributor.mu.RUnlock()
+	if lookup.resolved || lookup.backoff != ollamaBlobMinBackoff {
+		t.Errorf("unexpected lookup state: %+v", lookup)
+	}
+}
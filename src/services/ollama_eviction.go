@@ -0,0 +1,206 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/LanceLRQ/ollama-watchdog/configs"
+	"github.com/LanceLRQ/ollama-watchdog/models"
+)
+
+// 保留的驱逐记录条数
+const ollamaEvictionLogSize = 200
+
+// OllamaEvictor 当某块GPU的空闲显存持续低于阈值时，卸载该GPU上最久未使用或优先级最低的模型
+//
+// 模型与GPU的对应关系来自 OllamaModelAttributor，需要能读取本机 runner 进程的命令行。
+// 最近使用时间由轮询记录：Ollama 在每次请求后将 /api/ps 的 expires_at 更新为 当前时间 + keep_alive，
+// 模型首次出现或 expires_at 推后时记为当前时间，因此不受各模型 keep_alive 不同的影响。常驻模型永不驱逐
+type OllamaEvictor struct {
+	cfg    *configs.ServerConfigStruct
+	pinner *OllamaPinner
+
+	mu            sync.Mutex
+	activity      map[string]ollamaModelActivity // server|model -> 使用情况
+	pressureSince map[string]time.Time           // bus_id -> 开始低于阈值的时间
+	evicting      map[string]bool                // server|model -> 正在卸载
+	records       []models.OllamaEvictionRecord
+}
+
+type ollamaModelActivity struct {
+	server    string
+	expiresAt time.Time
+	lastUsed  time.Time
+}
+
+func NewOllamaEvictor(cfg *configs.ServerConfigStruct, pinner *OllamaPinner) *OllamaEvictor {
+	if cfg.EvictionEnabled && !IsLocalGPUCollector(cfg) {
+		fmt.Println("Warning: eviction is enabled, but GPU processes are collected by nvidia_smi_command and cannot be attributed to Ollama models, no model will be evicted")
+	}
+	return &OllamaEvictor{
+		cfg:           cfg,
+		pinner:        pinner,
+		activity:      map[string]ollamaModelActivity{},
+		pressureSince: map[string]time.Time{},
+		evicting:      map[string]bool{},
+	}
+}
+
+// UpdateOllamaPS 根据最新的 /api/ps 结果更新各模型的最近使用时间，请求失败的实例保留原有记录
+func (e *OllamaEvictor) UpdateOllamaPS(response models.OllamaPSResponse) {
+	now := time.Now()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	responded := map[string]bool{}
+	seen := map[string]bool{}
+	for _, instance := range response.Data {
+		if instance.Data == nil {
+			continue
+		}
+		responded[instance.Server] = true
+		for _, model := range instance.Data.Models {
+			key := instance.Server + "|" + normalizeOllamaModelName(model.Name)
+			seen[key] = true
+			activity, ok := e.activity[key]
+			if !ok || model.ExpiresAt.After(activity.expiresAt) {
+				activity.lastUsed = now
+			}
+			activity.server = instance.Server
+			activity.expiresAt = model.ExpiresAt
+			e.activity[key] = activity
+		}
+	}
+	// 已卸载的模型
+	for key, activity := range e.activity {
+		if responded[activity.server] && !seen[key] {
+			delete(e.activity, key)
+		}
+	}
+}
+
+// Check 根据最新的GPU采样检查显存压力，需要时驱逐模型
+func (e *OllamaEvictor) Check(response models.NvidiaSMIResponse) {
+	if !e.cfg.EvictionEnabled {
+		return
+	}
+	now := time.Now()
+	threshold := uint64(max(e.cfg.EvictionFreeMB, 0))
+	duration := time.Duration(e.cfg.EvictionDurationSec) * time.Second
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, gpu := range response.GPUInfo {
+		free := uint64(0)
+		if gpu.MemoryTotal > gpu.MemoryUsed {
+			free = gpu.MemoryTotal - gpu.MemoryUsed
+		}
+		if free >= threshold {
+			delete(e.pressureSince, gpu.BusId)
+			continue
+		}
+		since, ok := e.pressureSince[gpu.BusId]
+		if !ok {
+			e.pressureSince[gpu.BusId] = now
+			continue
+		}
+		if now.Sub(since) < duration {
+			continue
+		}
+
+		candidate, reason := e.pickCandidate(gpu.BusId, response.OllamaModels)
+		// 无论是否找到候选，都重新计时，避免每个采样周期都驱逐一次
+		e.pressureSince[gpu.BusId] = now
+		if candidate == nil {
+			continue
+		}
+		record := models.OllamaEvictionRecord{
+			Timestamp: now.Unix(),
+			Server:    candidate.Server,
+			Model:     candidate.Model,
+			BusId:     gpu.BusId,
+			FreeMB:    free,
+			Reason:    fmt.Sprintf("free VRAM %dMB < %dMB for %s, %s", free, threshold, duration, reason),
+			DryRun:    e.cfg.EvictionDryRun,
+		}
+		if record.DryRun {
+			fmt.Printf("[dry-run] would evict model %s on %s (%s)\n", record.Model, record.Server, record.Reason)
+			e.appendRecord(record)
+			continue
+		}
+		key := candidate.Server + "|" + candidate.Model
+		e.evicting[key] = true
+		go e.evict(key, record)
+	}
+}
+
+func (e *OllamaEvictor) evict(key string, record models.OllamaEvictionRecord) {
+	result := UnloadOllamaModel(record.Server, record.Model, 30*time.Second)
+	record.Result = result.Result
+	fmt.Printf("Evicted model %s on %s: %s (%s)\n", record.Model, record.Server, result.Result, record.Reason)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.evicting, key)
+	e.appendRecord(record)
+}
+
+// pickCandidate 在指定GPU上选出要驱逐的模型，调用方需持有锁
+func (e *OllamaEvictor) pickCandidate(busId string, usages []models.OllamaModelUsage) (*models.OllamaModelUsage, string) {
+	type candidate struct {
+		usage    models.OllamaModelUsage
+		lastUsed time.Time
+		priority int
+	}
+	var candidates []candidate
+	for _, usage := range usages {
+		if usage.BusId != busId || e.evicting[usage.Server+"|"+usage.Model] {
+			continue
+		}
+		if e.pinner != nil && e.pinner.IsPinned(usage.Server, usage.Model) {
+			continue
+		}
+		candidates = append(candidates, candidate{
+			usage:    usage,
+			lastUsed: e.activity[usage.Server+"|"+normalizeOllamaModelName(usage.Model)].lastUsed,
+			priority: e.cfg.EvictionPriorities[usage.Model],
+		})
+	}
+	if len(candidates) == 0 {
+		return nil, ""
+	}
+
+	if e.cfg.EvictionStrategy == "priority" {
+		sort.SliceStable(candidates, func(i, j int) bool {
+			if candidates[i].priority != candidates[j].priority {
+				return candidates[i].priority < candidates[j].priority
+			}
+			return candidates[i].lastUsed.Before(candidates[j].lastUsed)
+		})
+		return &candidates[0].usage, fmt.Sprintf("lowest priority (%d)", candidates[0].priority)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].lastUsed.Before(candidates[j].lastUsed)
+	})
+	return &candidates[0].usage, fmt.Sprintf("least recently used (last used at %s)", candidates[0].lastUsed.Format(time.RFC3339))
+}
+
+// appendRecord 记录驱逐日志，调用方需持有锁
+func (e *OllamaEvictor) appendRecord(record models.OllamaEvictionRecord) {
+	e.records = append(e.records, record)
+	if len(e.records) > ollamaEvictionLogSize {
+		e.records = e.records[len(e.records)-ollamaEvictionLogSize:]
+	}
+}
+
+// Records 获取驱逐记录，按时间倒序
+func (e *OllamaEvictor) Records() []models.OllamaEvictionRecord {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	list := make([]models.OllamaEvictionRecord, len(e.records))
+	for i, record := range e.records {
+		list[len(e.records)-1-i] = record
+	}
+	return list
+}
@@ -0,0 +1,210 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/LanceLRQ/ollama-watchdog/configs"
+	"github.com/LanceLRQ/ollama-watchdog/models"
+	"github.com/LanceLRQ/ollama-watchdog/ollama"
+)
+
+const evictionTestServer = "http://127.0.0.1:11434"
+
+func evictionTestConfig() configs.ServerConfigStruct {
+	cfg := configs.GetDefaultServerConfig()
+	cfg.EvictionEnabled = true
+	cfg.EvictionDryRun = true
+	cfg.EvictionFreeMB = 1024
+	cfg.EvictionDurationSec = 0
+	cfg.EvictionStrategy = "lru"
+	return cfg
+}
+
+// evictionPS 生成 /api/ps 结果，参数为 模型名 -> 过期时间
+func evictionPS(server string, expires map[string]time.Time) models.OllamaPSResponse {
+	ps := &ollama.PSResponse{}
+	for name, expiresAt := range expires {
+		ps.Models = append(ps.Models, ollama.ProcessModel{Name: name, Model: name, ExpiresAt: expiresAt})
+	}
+	return models.OllamaPSResponse{Data: []models.OllamaInstancePS{{Server: server, Status: true, Data: ps}}}
+}
+
+// evictionPressure 生成 gpu0 空闲显存不足的采样，模型均在 gpu0 上
+func evictionPressure(server string, names ...string) models.NvidiaSMIResponse {
+	response := models.NvidiaSMIResponse{
+		GPUInfo: []models.GPUInfo{{BusId: "gpu0", MemoryTotal: 24576, MemoryUsed: 24000}},
+	}
+	for i, name := range names {
+		response.OllamaModels = append(response.OllamaModels, models.OllamaModelUsage{
+			Server: server, Model: name, PID: uint64(100 + i), BusId: "gpu0", MemoryUsed: 8000,
+		})
+	}
+	return response
+}
+
+// evictOnce 持续压力下检查两次（第一次开始计时），返回新增的驱逐记录
+func evictOnce(e *OllamaEvictor, response models.NvidiaSMIResponse) []models.OllamaEvictionRecord {
+	before := len(e.Records())
+	e.Check(response)
+	e.Check(response)
+	records := e.Records()
+	return records[:len(records)-before]
+}
+
+func TestOllamaEvictorSkipsPinned(t *testing.T) {
+	cfg := evictionTestConfig()
+	cfg.OllamaPins = []configs.OllamaPinConfig{{Server: evictionTestServer, Model: "llama3"}}
+	evictor := NewOllamaEvictor(&cfg, NewOllamaPinner(&cfg))
+	now := time.Now()
+	// 常驻模型最久未使用，也不能被驱逐
+	evictor.UpdateOllamaPS(evictionPS(evictionTestServer, map[string]time.Time{"llama3:latest": now.Add(time.Hour)}))
+	time.Sleep(5 * time.Millisecond)
+	evictor.UpdateOllamaPS(evictionPS(evictionTestServer, map[string]time.Time{"llama3:latest": now.Add(time.Hour), "qwen2.5:7b": now.Add(time.Minute)}))
+
+	records := evictOnce(evictor, evictionPressure(evictionTestServer, "llama3:latest", "qwen2.5:7b"))
+	if len(records) != 1 || records[0].Model != "qwen2.5:7b" {
+		t.Fatalf("records = %+v, want qwen2.5:7b evicted", records)
+	}
+	if records := evictOnce(evictor, evictionPressure(evictionTestServer, "llama3:latest")); len(records) != 0 {
+		t.Errorf("pinned model evicted: %+v", records)
+	}
+}
+
+func TestOllamaEvictorDryRun(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer srv.Close()
+
+	cfg := evictionTestConfig()
+	evictor := NewOllamaEvictor(&cfg, nil)
+	evictor.UpdateOllamaPS(evictionPS(srv.URL, map[string]time.Time{"llama3:latest": time.Now().Add(time.Minute)}))
+	records := evictOnce(evictor, evictionPressure(srv.URL, "llama3:latest"))
+	if len(records) != 1 || !records[0].DryRun || records[0].Result != "" || records[0].BusId != "gpu0" || records[0].FreeMB != 576 {
+		t.Fatalf("records = %+v, want one dry-run record", records)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := requests.Load(); n != 0 {
+		t.Errorf("dry run sent %d requests to Ollama", n)
+	}
+}
+
+func TestOllamaEvictorUnloads(t *testing.T) {
+	unloaded := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/ps":
+			fmt.Fprintln(w, `{"models":[{"name":"llama3:latest","model":"llama3:latest"}]}`)
+		case "/api/generate":
+			unloaded <- r.URL.Path
+			fmt.Fprintln(w, `{"done":true}`)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := evictionTestConfig()
+	cfg.EvictionDryRun = false
+	evictor := NewOllamaEvictor(&cfg, nil)
+	evictor.UpdateOllamaPS(evictionPS(srv.URL, map[string]time.Time{"llama3:latest": time.Now().Add(time.Minute)}))
+	evictor.Check(evictionPressure(srv.URL, "llama3:latest"))
+	evictor.Check(evictionPressure(srv.URL, "llama3:latest"))
+	select {
+	case <-unloaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("model was not unloaded")
+	}
+	var records []models.OllamaEvictionRecord
+	for deadline := time.Now().Add(time.Second); len(records) == 0 && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		records = evictor.Records()
+	}
+	if len(records) != 1 || records[0].DryRun || records[0].Result != models.OllamaUnloadSuccess {
+		t.Errorf("records = %+v, want one successful eviction", records)
+	}
+}
+
+func TestOllamaEvictorStrategy(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name       string
+		strategy   string
+		priorities map[string]int
+		want       string
+	}{
+		// a 最先加载但最近被使用过（keep_alive 较短，expires_at 仍然最早），b 最久未使用
+		{"lru ignores keep_alive", "lru", nil, "b:latest"},
+		{"lowest priority", "priority", map[string]int{"a:latest": 1, "b:latest": 5, "c:latest": 3}, "a:latest"},
+		{"priority tie falls back to lru", "priority", map[string]int{"a:latest": 2, "b:latest": 2, "c:latest": 1}, "c:latest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := evictionTestConfig()
+			cfg.EvictionStrategy = tt.strategy
+			cfg.EvictionPriorities = tt.priorities
+			evictor := NewOllamaEvictor(&cfg, nil)
+
+			expires := map[string]time.Time{"a:latest": now.Add(5 * time.Minute)}
+			evictor.UpdateOllamaPS(evictionPS(evictionTestServer, expires))
+			time.Sleep(5 * time.Millisecond)
+			expires["b:latest"] = now.Add(24 * time.Hour)
+			evictor.UpdateOllamaPS(evictionPS(evictionTestServer, expires))
+			time.Sleep(5 * time.Millisecond)
+			expires["c:latest"] = now.Add(time.Hour)
+			evictor.UpdateOllamaPS(evictionPS(evictionTestServer, expires))
+			time.Sleep(5 * time.Millisecond)
+			expires["a:latest"] = now.Add(6 * time.Minute)
+			evictor.UpdateOllamaPS(evictionPS(evictionTestServer, expires))
+
+			records := evictOnce(evictor, evictionPressure(evictionTestServer, "a:latest", "b:latest", "c:latest"))
+			if len(records) != 1 || records[0].Model != tt.want {
+				t.Errorf("records = %+v, want %s evicted", records, tt.want)
+			}
+		})
+	}
+}
+
+func TestOllamaEvictorPressureWindow(t *testing.T) {
+	cfg := evictionTestConfig()
+	cfg.EvictionDurationSec = 60
+	evictor := NewOllamaEvictor(&cfg, nil)
+	evictor.UpdateOllamaPS(evictionPS(evictionTestServer, map[string]time.Time{"llama3:latest": time.Now().Add(time.Minute)}))
+	pressure := evictionPressure(evictionTestServer, "llama3:latest")
+	setPressureSince := func(ago time.Duration) {
+		evictor.mu.Lock()
+		evictor.pressureSince["gpu0"] = time.Now().Add(-ago)
+		evictor.mu.Unlock()
+	}
+
+	// 压力未持续足够久
+	evictor.Check(pressure)
+	evictor.Check(pressure)
+	if records := evictor.Records(); len(records) != 0 {
+		t.Fatalf("evicted before the pressure window: %+v", records)
+	}
+
+	// 空闲显存恢复后重新计时
+	relieved := pressure
+	relieved.GPUInfo = []models.GPUInfo{{BusId: "gpu0", MemoryTotal: 24576, MemoryUsed: 1000}}
+	setPressureSince(2 * time.Minute)
+	evictor.Check(relieved)
+	evictor.Check(pressure)
+	if records := evictor.Records(); len(records) != 0 {
+		t.Fatalf("evicted although the pressure was relieved: %+v", records)
+	}
+
+	setPressureSince(2 * time.Minute)
+	evictor.Check(pressure)
+	if records := evictor.Records(); len(records) != 1 {
+		t.Fatalf("records = %+v, want one eviction after the pressure window", records)
+	}
+	// 驱逐后重新计时，不会每个采样周期都驱逐
+	evictor.Check(pressure)
+	if records := evictor.Records(); len(records) != 1 {
+		t.Errorf("records = %+v, want no further eviction within the window", records)
+	}
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/LanceLRQ/ollama-watchdog/configs"
+	"github.com/LanceLRQ/ollama-watchdog/models"
+	"github.com/LanceLRQ/ollama-watchdog/ollama"
+)
+
+// 统计状态抖动的时间窗口
+const ollamaFlapWindow = 5 * time.Minute
+
+// 版本信息的刷新间隔
+const ollamaVersionRefresh = time.Minute
+
+// OllamaHealthTracker 记录每个 Ollama 实例的健康状态，状态变化时通知订阅者
+//
+// 状态机：成功 -> up（响应过慢或抖动时为 degraded）；失败但未达到连续失败阈值 -> degraded；
+// 连续失败达到阈值 -> down
+type OllamaHealthTracker struct {
+	failThreshold int
+	flapThreshold int
+	slow          time.Duration
+
+	mu          sync.Mutex
+	instances   map[string]*ollamaHealthRecord
+	subscribers []func(models.OllamaHealthEvent)
+}
+
+type ollamaHealthRecord struct {
+	health      models.OllamaInstanceHealth
+	transitions []time.Time
+	versionAt   time.Time
+}
+
+func NewOllamaHealthTracker(cfg *configs.ServerConfigStruct) *OllamaHealthTracker {
+	failThreshold := cfg.OllamaFailThreshold
+	if failThreshold <= 0 {
+		failThreshold = 3
+	}
+	flapThreshold := cfg.OllamaFlapThreshold
+	if flapThreshold <= 0 {
+		flapThreshold = 4
+	}
+	return &OllamaHealthTracker{
+		failThreshold: failThreshold,
+		flapThreshold: flapThreshold,
+		slow:          time.Duration(cfg.OllamaSlowMs) * time.Millisecond,
+		instances:     map[string]*ollamaHealthRecord{},
+	}
+}
+
+// Subscribe 订阅状态变化事件，回调在记录状态的协程中同步执行，不应阻塞
+func (t *OllamaHealthTracker) Subscribe(fn func(models.OllamaHealthEvent)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.subscribers = append(t.subscribers, fn)
+}
+
+// Observe 记录一次探测结果，返回记录后的健康状态
+func (t *OllamaHealthTracker) Observe(server string, latency time.Duration, err error) models.OllamaInstanceHealth {
+	now := time.Now()
+
+	t.mu.Lock()
+	record := t.record(server)
+	health := &record.health
+	from := health.State
+
+	var to models.OllamaHealthState
+	var reason string
+	if err != nil {
+		health.ConsecutiveFailures++
+		health.LastError = err.Error()
+		reason = health.LastError
+		if health.ConsecutiveFailures >= t.failThreshold {
+			to = models.OllamaHealthDown
+		} else {
+			to = models.OllamaHealthDegraded
+		}
+	} else {
+		health.ConsecutiveFailures = 0
+		health.LastError = ""
+		health.LastHealthy = now.Unix()
+		to = models.OllamaHealthUp
+		if t.slow > 0 && latency > t.slow {
+			to = models.OllamaHealthDegraded
+			reason = fmt.Sprintf("slow response: %dms", latency.Milliseconds())
+		}
+	}
+
+	// 只保留时间窗口内的状态切换记录
+	transitions := record.transitions[:0]
+	for _, at := range record.transitions {
+		if now.Sub(at) <= ollamaFlapWindow {
+			transitions = append(transitions, at)
+		}
+	}
+	record.transitions = transitions
+	if from != to && from != models.OllamaHealthUnknown {
+		record.transitions = append(record.transitions, now)
+	}
+	health.Flapping = len(record.transitions) >= t.flapThreshold
+	if health.Flapping && to == models.OllamaHealthUp {
+		to = models.OllamaHealthDegraded
+		reason = "flapping"
+	}
+
+	var event *models.OllamaHealthEvent
+	if from != to {
+		health.State = to
+		health.LastChange = now.Unix()
+		if reason == "" {
+			reason = "healthy"
+		}
+		event = &models.OllamaHealthEvent{
+			Server:    server,
+			From:      from,
+			To:        to,
+			Reason:    reason,
+			Timestamp: now.Unix(),
+		}
+	}
+	result := t.snapshot(record, now)
+	subscribers := t.subscribers
+	t.mu.Unlock()
+
+	if event != nil {
+		for _, fn := range subscribers {
+			fn(*event)
+		}
+	}
+	return result
+}
+
+// NeedVersion 判断是否需要刷新实例版本
+func (t *OllamaHealthTracker) NeedVersion(server string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return time.Since(t.record(server).versionAt) > ollamaVersionRefresh
+}
+
+// SetVersion 记录实例版本
+func (t *OllamaHealthTracker) SetVersion(server string, version string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	record := t.record(server)
+	record.health.Version = version
+	record.versionAt = time.Now()
+}
+
+// Get 获取单个实例的健康状态
+func (t *OllamaHealthTracker) Get(server string) models.OllamaInstanceHealth {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.snapshot(t.record(server), time.Now())
+}
+
+// List 获取所有实例的健康状态
+func (t *OllamaHealthTracker) List() []models.OllamaInstanceHealth {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	list := make([]models.OllamaInstanceHealth, 0, len(t.instances))
+	for _, record := range t.instances {
+		list = append(list, t.snapshot(record, now))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Server < list[j].Server })
+	return list
+}
+
+func (t *OllamaHealthTracker) record(server string) *ollamaHealthRecord {
+	record, ok := t.instances[server]
+	if !ok {
+		record = &ollamaHealthRecord{health: models.OllamaInstanceHealth{
+			Server: server,
+			State:  models.OllamaHealthUnknown,
+		}}
+		t.instances[server] = record
+	}
+	return record
+}
+
+func (t *OllamaHealthTracker) snapshot(record *ollamaHealthRecord, now time.Time) models.OllamaInstanceHealth {
+	health := record.health
+	if health.LastHealthy > 0 {
+		health.SinceHealthy = now.Unix() - health.LastHealthy
+	}
+	return health
+}
+
+// refreshOllamaVersion 实例在线时定期获取 /api/version
+//
+// server 为配置中的原始地址，与 Observe 使用相同的键（client.Host 会去掉末尾的斜杠）
+func refreshOllamaVersion(tracker *OllamaHealthTracker, server string, client *ollama.Client, timeout time.Duration) {
+	if !tracker.NeedVersion(server) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if resp, err := client.Version(ctx); err == nil {
+		tracker.SetVersion(server, resp.Version)
+	}
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/LanceLRQ/ollama-watchdog/configs"
+	"github.com/LanceLRQ/ollama-watchdog/models"
+)
+
+func TestOllamaHealthTrackerTransitions(t *testing.T) {
+	cfg := configs.GetDefaultServerConfig()
+	cfg.OllamaFailThreshold = 2
+	tracker := NewOllamaHealthTracker(&cfg)
+	var events []models.OllamaHealthEvent
+	tracker.Subscribe(func(e models.OllamaHealthEvent) { events = append(events, e) })
+
+	failure := errors.New("connection refused")
+	want := []models.OllamaHealthState{
+		models.OllamaHealthUp,
+		models.OllamaHealthDegraded,
+		models.OllamaHealthDown,
+		models.OllamaHealthUp,
+	}
+	for i, err := range []error{nil, failure, failure, nil} {
+		if got := tracker.Observe("http://a", time.Millisecond, err).State; got != want[i] {
+			t.Fatalf("step %d: state = %s, want %s", i, got, want[i])
+		}
+	}
+	if len(events) != 4 || events[2].To != models.OllamaHealthDown || events[2].Reason != "connection refused" {
+		t.Errorf("unexpected events: %+v", events)
+	}
+}
+
+func TestGetOllamaPSHealthKey(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/ps":
+			fmt.Fprint(w, `{"models":[]}`)
+		case "/api/version":
+			fmt.Fprint(w, `{"version":"0.3.12"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	// 配置中的地址带末尾斜杠时，健康记录与版本信息仍应落在同一个键下
+	cfg := configs.GetDefaultServerConfig()
+	cfg.OllamaListens = []string{srv.URL + "/"}
+	tracker := NewOllamaHealthTracker(&cfg)
+	resp := GetOllamaPS(&cfg, tracker)
+	if !resp.Status || len(resp.Data) != 1 || !resp.Data[0].Status {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+
+	list := tracker.List()
+	if len(list) != 1 {
+		t.Fatalf("got %d health records, want 1: %+v", len(list), list)
+	}
+	if list[0].Server != srv.URL+"/" || list[0].Version != "0.3.12" || list[0].State != models.OllamaHealthUp {
+		t.Errorf("unexpected health: %+v", list[0])
+	}
+}
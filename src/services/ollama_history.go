@@ -0,0 +1,143 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/LanceLRQ/ollama-watchdog/configs"
+	"github.com/LanceLRQ/ollama-watchdog/models"
+	"github.com/dgraph-io/badger/v4"
+)
+
+// OllamaHistoryRecorder 记录各实例已加载模型的历史
+//
+// 对比相邻两次 /api/ps 结果得到加载、卸载事件，并按 ollama_snapshot_interval_ms 定期保存完整快照；
+// 请求失败的实例保留上一次的结果，不产生卸载事件。事件与快照分别保存在 ollama_event、ollama 系列下
+type OllamaHistoryRecorder struct {
+	db  *badger.DB
+	cfg *configs.ServerConfigStruct
+
+	mu           sync.Mutex
+	loaded       map[string]map[string]models.OllamaLoadedModel // server -> model -> 模型信息
+	lastSnapshot time.Time
+}
+
+func NewOllamaHistoryRecorder(db *badger.DB, cfg *configs.ServerConfigStruct) *OllamaHistoryRecorder {
+	return &OllamaHistoryRecorder{
+		db:     db,
+		cfg:    cfg,
+		loaded: map[string]map[string]models.OllamaLoadedModel{},
+	}
+}
+
+// Record 根据最新的 /api/ps 结果记录事件与快照
+func (r *OllamaHistoryRecorder) Record(response models.OllamaPSResponse) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	snapshot := models.OllamaPSSnapshot{
+		Models:      make([]models.OllamaLoadedModel, 0),
+		Unreachable: make([]string, 0),
+		Timestamp:   now.Unix(),
+		TimestampMs: now.UnixMilli(),
+	}
+	var events []models.OllamaModelEvent
+	event := func(kind string, model models.OllamaLoadedModel) {
+		events = append(events, models.OllamaModelEvent{
+			OllamaLoadedModel: model,
+			Event:             kind,
+			Timestamp:         now.Unix(),
+			TimestampMs:       now.UnixMilli(),
+		})
+	}
+
+	for _, instance := range response.Data {
+		if !instance.Status || instance.Data == nil {
+			snapshot.Unreachable = append(snapshot.Unreachable, instance.Server)
+			continue
+		}
+		current := map[string]models.OllamaLoadedModel{}
+		for _, m := range instance.Data.Models {
+			loaded := models.OllamaLoadedModel{
+				Server:   instance.Server,
+				Model:    m.Name,
+				Size:     m.Size,
+				SizeVRAM: m.SizeVRAM,
+			}
+			if !m.ExpiresAt.IsZero() {
+				loaded.ExpiresAt = m.ExpiresAt.Unix()
+			}
+			current[m.Name] = loaded
+			snapshot.Models = append(snapshot.Models, loaded)
+		}
+
+		// 首次成功请求只作为基准，不产生事件
+		if previous, ok := r.loaded[instance.Server]; ok {
+			for name, loaded := range current {
+				if _, ok := previous[name]; !ok {
+					event(models.OllamaModelLoaded, loaded)
+				}
+			}
+			for name, loaded := range previous {
+				if _, ok := current[name]; !ok {
+					event(models.OllamaModelUnloaded, loaded)
+				}
+			}
+		}
+		r.loaded[instance.Server] = current
+	}
+
+	if len(events) > 0 {
+		for _, e := range events {
+			fmt.Printf("Ollama model %s on %s: %s\n", e.Model, e.Server, e.Event)
+		}
+		if err := saveSample(r.db, "ollama_event", now.UnixMilli(), r.cfg.RetentionOf("ollama_event"), events); err != nil {
+			fmt.Printf("%s\n", err.Error())
+		}
+	}
+	// 有事件时立即保存快照，保证事件前后的状态都有记录
+	interval := configs.IntervalFromMs(r.cfg.OllamaSnapshotIntervalMs, time.Minute)
+	if len(events) > 0 || now.Sub(r.lastSnapshot) >= interval {
+		r.lastSnapshot = now
+		if err := saveSample(r.db, "ollama", now.UnixMilli(), r.cfg.RetentionOf("ollama"), snapshot); err != nil {
+			fmt.Printf("%s\n", err.Error())
+		}
+	}
+}
+
+// LoadOllamaHistory 读取 startMs 之后的模型事件与快照，server、model 不为空时只返回匹配的记录
+func LoadOllamaHistory(db *badger.DB, startMs int64, server string, model string) ([]models.OllamaModelEvent, []models.OllamaPSSnapshot, error) {
+	match := func(m models.OllamaLoadedModel) bool {
+		return (server == "" || m.Server == server) && (model == "" || normalizeOllamaModelName(m.Model) == normalizeOllamaModelName(model))
+	}
+
+	batches, err := LoadSamples[[]models.OllamaModelEvent](db, "ollama_event", startMs)
+	if err != nil {
+		return nil, nil, err
+	}
+	events := make([]models.OllamaModelEvent, 0)
+	for _, batch := range batches {
+		for _, e := range batch {
+			if match(e.OllamaLoadedModel) {
+				events = append(events, e)
+			}
+		}
+	}
+
+	snapshots, err := LoadSamples[models.OllamaPSSnapshot](db, "ollama", startMs)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range snapshots {
+		filtered := make([]models.OllamaLoadedModel, 0, len(snapshots[i].Models))
+		for _, m := range snapshots[i].Models {
+			if match(m) {
+				filtered = append(filtered, m)
+			}
+		}
+		snapshots[i].Models = filtered
+	}
+	return events, snapshots, nil
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LanceLRQ/ollama-watchdog/configs"
+	"github.com/LanceLRQ/ollama-watchdog/models"
+	"github.com/LanceLRQ/ollama-watchdog/utils"
+)
+
+// 两次自动处理之间的最小间隔
+const ollamaHungRemediationCooldown = 5 * time.Minute
+
+// OllamaHungDetector 检测推理卡死：实例使用的GPU持续满载，但 Ollama 探测超时、无法连接或响应过慢
+//
+// 实例使用的GPU由 OllamaModelAttributor 关联的 runner 进程得到；探测结果来自 /api/ps 轮询和合成探测。
+// Ollama 返回的错误状态（如模型不存在）说明服务仍在响应，不算无响应；超过两个探测周期未更新的结果视为过期
+type OllamaHungDetector struct {
+	cfg *configs.ServerConfigStruct
+
+	mu     sync.Mutex
+	probes map[string]map[string]ollamaProbeResult // server -> 探测来源 -> 最近结果
+	states map[string]*models.OllamaHungState
+}
+
+type ollamaProbeResult struct {
+	latency     time.Duration
+	err         string
+	unreachable bool
+	expireAt    time.Time
+}
+
+func NewOllamaHungDetector(cfg *configs.ServerConfigStruct) *OllamaHungDetector {
+	return &OllamaHungDetector{
+		cfg:    cfg,
+		probes: map[string]map[string]ollamaProbeResult{},
+		states: map[string]*models.OllamaHungState{},
+	}
+}
+
+// UpdateOllamaPS 记录 /api/ps 轮询的探测结果
+func (d *OllamaHungDetector) UpdateOllamaPS(response models.OllamaPSResponse) {
+	expireAt := time.Now().Add(2 * configs.IntervalFromMs(d.cfg.OllamaIntervalMs, time.Second))
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, instance := range response.Data {
+		d.observe(instance.Server, "ps", ollamaProbeResult{
+			latency:     time.Duration(instance.LatencyMs) * time.Millisecond,
+			err:         instance.Error,
+			unreachable: instance.Unreachable,
+			expireAt:    expireAt,
+		})
+	}
+}
+
+// UpdateProbes 记录一轮合成探测的结果
+func (d *OllamaHungDetector) UpdateProbes(response models.OllamaProbeResponse) {
+	expireAt := time.Now().Add(2 * configs.IntervalFromMs(d.cfg.ProbeIntervalMs, time.Minute))
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, result := range response.Results {
+		// 只关心推理本身的响应，去掉模型加载耗时
+		latency := time.Duration(max(0, result.TTFTMs-result.LoadDurationMs)) * time.Millisecond
+		d.observe(result.Server, "probe:"+result.Model, ollamaProbeResult{
+			latency:     latency,
+			err:         result.Error,
+			unreachable: result.Unreachable,
+			expireAt:    expireAt,
+		})
+	}
+}
+
+// observe 调用方需持有锁
+func (d *OllamaHungDetector) observe(server string, source string, result ollamaProbeResult) {
+	if d.probes[server] == nil {
+		d.probes[server] = map[string]ollamaProbeResult{}
+	}
+	d.probes[server][source] = result
+}
+
+// Check 根据最新的GPU采样判断各实例是否卡死
+func (d *OllamaHungDetector) Check(response models.NvidiaSMIResponse) {
+	if !d.cfg.HungEnabled {
+		return
+	}
+	now := time.Now()
+	slow := time.Duration(d.cfg.HungLatencyMs) * time.Millisecond
+	duration := time.Duration(d.cfg.HungDurationSec) * time.Second
+
+	gpuUsed := map[string]uint64{}
+	for _, gpu := range response.GPUInfo {
+		gpuUsed[gpu.BusId] = gpu.GPUUsed
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, server := range d.cfg.OllamaListens {
+		state := d.state(server)
+
+		var busIds []string
+		var pids []uint64
+		var modelNames []string
+		for _, usage := range response.OllamaModels {
+			if usage.Server != server {
+				continue
+			}
+			if !slices.Contains(busIds, usage.BusId) {
+				busIds = append(busIds, usage.BusId)
+			}
+			pids = append(pids, usage.PID)
+			if !slices.Contains(modelNames, usage.Model) {
+				modelNames = append(modelNames, usage.Model)
+			}
+		}
+		sort.Strings(busIds)
+		state.BusIds = busIds
+		state.GPUUsed = 0
+		for _, busId := range busIds {
+			state.GPUUsed = max(state.GPUUsed, gpuUsed[busId])
+		}
+
+		unresponsive := false
+		state.LatencyMs = 0
+		state.LastError = ""
+		for source, probe := range d.probes[server] {
+			if now.After(probe.expireAt) {
+				delete(d.probes[server], source)
+				continue
+			}
+			state.LatencyMs = max(state.LatencyMs, probe.latency.Milliseconds())
+			if probe.err != "" {
+				state.LastError = source + ": " + probe.err
+			}
+			if probe.unreachable || (slow > 0 && probe.latency >= slow) {
+				unresponsive = true
+			}
+		}
+
+		busy := len(busIds) > 0 && state.GPUUsed >= uint64(d.cfg.HungGPUUtil)
+		if !busy || !unresponsive {
+			if state.Hung {
+				fmt.Printf("Ollama instance %s recovered from hung state\n", server)
+			}
+			state.Hung = false
+			state.MismatchSince = 0
+			continue
+		}
+		if state.MismatchSince == 0 {
+			state.MismatchSince = now.Unix()
+		}
+		if now.Sub(time.Unix(state.MismatchSince, 0)) < duration {
+			continue
+		}
+		if !state.Hung {
+			state.Hung = true
+			fmt.Printf("Ollama instance %s is hung: GPU %s at %d%%, probe %s\n", server, strings.Join(busIds, ","), state.GPUUsed, state.LastError)
+		}
+
+		remediation := d.cfg.HungRemediation
+		if remediation == "" || remediation == "none" || now.Sub(time.Unix(state.LastRemediation, 0)) < ollamaHungRemediationCooldown {
+			continue
+		}
+		serviceName := ""
+		if i < len(d.cfg.OllamaServices) {
+			serviceName = d.cfg.OllamaServices[i]
+		}
+		state.LastRemediation = now.Unix()
+		state.Remediation = remediation + ": running"
+		go d.remediate(server, serviceName, remediation, modelNames, pids)
+	}
+}
+
+func (d *OllamaHungDetector) remediate(server string, serviceName string, remediation string, modelNames []string, pids []uint64) {
+	var errs []string
+	switch remediation {
+	case "unload":
+		for _, model := range modelNames {
+			if result := UnloadOllamaModel(server, model, 30*time.Second); result.Result != models.OllamaUnloadSuccess {
+				errs = append(errs, fmt.Sprintf("%s: %s", model, result.Result))
+			}
+		}
+	case "kill":
+		for _, pid := range pids {
+			if err := utils.TerminateProcess(int(pid)); err != nil {
+				errs = append(errs, fmt.Sprintf("pid %d: %s", pid, err.Error()))
+			}
+		}
+	case "restart":
+		if serviceName == "" {
+			errs = append(errs, "ollama_services is not configured for this instance")
+		} else if err := utils.RestartServiceProcess("restart", serviceName); err != nil {
+			errs = append(errs, err.Error())
+		}
+	default:
+		errs = append(errs, "unsupported remediation")
+	}
+
+	result := remediation + ": success"
+	if len(errs) > 0 {
+		result = remediation + ": failed (" + strings.Join(errs, "; ") + ")"
+	}
+	fmt.Printf("Ollama instance %s hung remediation %s\n", server, result)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.state(server).Remediation = result
+}
+
+// States 获取所有实例的卡死检测状态
+func (d *OllamaHungDetector) States() []models.OllamaHungState {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	list := make([]models.OllamaHungState, 0, len(d.states))
+	for _, server := range d.cfg.OllamaListens {
+		if state, ok := d.states[server]; ok {
+			list = append(list, *state)
+		}
+	}
+	return list
+}
+
+// state 调用方需持有锁
+func (d *OllamaHungDetector) state(server string) *models.OllamaHungState {
+	state, ok := d.states[server]
+	if !ok {
+		state = &models.OllamaHungState{Server: server}
+		d.states[server] = state
+	}
+	return state
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LanceLRQ/ollama-watchdog/configs"
+	"github.com/LanceLRQ/ollama-watchdog/models"
+	"github.com/LanceLRQ/ollama-watchdog/ollama"
+)
+
+func TestOllamaHungDetectorClassification(t *testing.T) {
+	const server = "http://127.0.0.1:11434"
+	cfg := configs.GetDefaultServerConfig()
+	cfg.OllamaListens = []string{server}
+	cfg.HungEnabled = true
+	cfg.HungGPUUtil = 90
+	cfg.HungLatencyMs = 5000
+	cfg.HungDurationSec = 0
+	cfg.HungRemediation = "none"
+	busy := models.NvidiaSMIResponse{
+		GPUInfo:      []models.GPUInfo{{BusId: "00000000:01:00.0", GPUUsed: 100}},
+		OllamaModels: []models.OllamaModelUsage{{Server: server, Model: "llama3:8b", PID: 1234, BusId: "00000000:01:00.0"}},
+	}
+
+	tests := []struct {
+		name     string
+		instance models.OllamaInstancePS
+		probe    *models.OllamaProbeResult
+		want     bool
+	}{
+		{
+			name:     "healthy",
+			instance: models.OllamaInstancePS{Server: server, Status: true, LatencyMs: 10},
+		},
+		{
+			name:     "ps unreachable",
+			instance: models.OllamaInstancePS{Server: server, Error: "context deadline exceeded", Unreachable: true},
+			want:     true,
+		},
+		{
+			name:     "ps slow",
+			instance: models.OllamaInstancePS{Server: server, Status: true, LatencyMs: 6000},
+			want:     true,
+		},
+		{
+			name:     "probe model not found",
+			instance: models.OllamaInstancePS{Server: server, Status: true, LatencyMs: 10},
+			probe:    &models.OllamaProbeResult{Server: server, Model: "missing", Error: `model "missing" not found (status 404)`},
+		},
+		{
+			name:     "probe timeout",
+			instance: models.OllamaInstancePS{Server: server, Status: true, LatencyMs: 10},
+			probe:    &models.OllamaProbeResult{Server: server, Model: "llama3:8b", Error: "timeout after 30s", Unreachable: true},
+			want:     true,
+		},
+		{
+			name:     "probe slow after load",
+			instance: models.OllamaInstancePS{Server: server, Status: true, LatencyMs: 10},
+			probe:    &models.OllamaProbeResult{Server: server, Model: "llama3:8b", Success: true, TTFTMs: 9000, LoadDurationMs: 8000},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detector := NewOllamaHungDetector(&cfg)
+			detector.UpdateOllamaPS(models.OllamaPSResponse{Data: []models.OllamaInstancePS{tt.instance}})
+			if tt.probe != nil {
+				detector.UpdateProbes(models.OllamaProbeResponse{Results: []models.OllamaProbeResult{*tt.probe}})
+			}
+			detector.Check(busy)
+			if got := detector.States()[0].Hung; got != tt.want {
+				t.Errorf("hung = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOllamaHungDetectorExpiresProbes(t *testing.T) {
+	const server = "http://127.0.0.1:11434"
+	cfg := configs.GetDefaultServerConfig()
+	cfg.OllamaListens = []string{server}
+	cfg.HungEnabled = true
+	cfg.HungGPUUtil = 90
+	cfg.HungDurationSec = 0
+	cfg.HungRemediation = "none"
+	cfg.ProbeIntervalMs = 100
+	busy := models.NvidiaSMIResponse{
+		GPUInfo:      []models.GPUInfo{{BusId: "00000000:01:00.0", GPUUsed: 100}},
+		OllamaModels: []models.OllamaModelUsage{{Server: server, Model: "llama3:8b", PID: 1234, BusId: "00000000:01:00.0"}},
+	}
+
+	detector := NewOllamaHungDetector(&cfg)
+	detector.UpdateProbes(models.OllamaProbeResponse{Results: []models.OllamaProbeResult{
+		{Server: server, Model: "llama3:8b", Error: "timeout after 30s", Unreachable: true},
+	}})
+	detector.Check(busy)
+	if !detector.States()[0].Hung {
+		t.Fatal("expected hung with a fresh timeout probe")
+	}
+
+	// 探测停止更新超过两个周期后不再作为依据
+	time.Sleep(250 * time.Millisecond)
+	detector.Check(busy)
+	if state := detector.States()[0]; state.Hung || state.LastError != "" {
+		t.Errorf("stale probe still counted: %+v", state)
+	}
+}
+
+func TestOllamaHungDetectorPSTimeout(t *testing.T) {
+	blob := strings.Repeat("ab", 32)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"modelfile":"FROM /usr/share/ollama/.ollama/models/blobs/sha256-%s\n"}`, blob)
+	}))
+	defer srv.Close()
+
+	cfg := configs.GetDefaultServerConfig()
+	cfg.OllamaListens = []string{srv.URL}
+	cfg.HungEnabled = true
+	cfg.HungGPUUtil = 90
+	cfg.HungDurationSec = 0
+	cfg.HungRemediation = "none"
+	attributor := NewOllamaModelAttributor(&cfg)
+	detector := NewOllamaHungDetector(&cfg)
+	sample := models.NvidiaSMIResponse{
+		GPUInfo: []models.GPUInfo{{BusId: "00000000:01:00.0", GPUUsed: 100}},
+		GPUProcesses: []models.GPUProcess{{
+			BusId:   "00000000:01:00.0",
+			PID:     1234,
+			Cmdline: "/usr/local/bin/ollama runner --model /usr/share/ollama/.ollama/models/blobs/sha256-" + blob + " --port 40000",
+		}},
+	}
+	check := func(instance models.OllamaInstancePS) models.OllamaHungState {
+		response := models.OllamaPSResponse{Data: []models.OllamaInstancePS{instance}}
+		attributor.UpdateOllamaPS(response)
+		detector.UpdateOllamaPS(response)
+		sample.OllamaModels = attributor.Attribute(sample.GPUProcesses)
+		detector.Check(sample)
+		return detector.States()[0]
+	}
+
+	healthy := models.OllamaInstancePS{Server: srv.URL, Status: true, Data: &ollama.PSResponse{
+		Models: []ollama.ProcessModel{{Name: "llama3:8b", Model: "llama3:8b", Digest: "d1"}},
+	}}
+	waitForOllamaBlobs(t, attributor, models.OllamaPSResponse{Data: []models.OllamaInstancePS{healthy}}, 1)
+	if state := check(healthy); state.Hung || len(sample.OllamaModels) != 1 {
+		t.Fatalf("healthy ps: state %+v, usages %+v", state, sample.OllamaModels)
+	}
+
+	// /api/ps 超时后仍沿用上次的模型关联，GPU满载时判定为卡死
+	timeout := models.OllamaInstancePS{Server: srv.URL, Error: "context deadline exceeded", Unreachable: true}
+	if state := check(timeout); !state.Hung || len(state.BusIds) != 1 {
+		t.Errorf("ps timeout: state %+v, usages %+v", state, sample.OllamaModels)
+	}
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/LanceLRQ/ollama-watchdog/configs"
+	"github.com/LanceLRQ/ollama-watchdog/models"
+	"github.com/LanceLRQ/ollama-watchdog/ollama"
+)
+
+// OllamaInventory 定期拉取每个实例的 /api/tags，汇总各实例上的模型，用于发现节点间的模型差异
+type OllamaInventory struct {
+	cfg *configs.ServerConfigStruct
+
+	mu       sync.RWMutex
+	snapshot models.OllamaInventoryResponse
+}
+
+func NewOllamaInventory(cfg *configs.ServerConfigStruct) *OllamaInventory {
+	return &OllamaInventory{cfg: cfg}
+}
+
+// OllamaInventoryWatcher 立即拉取一次清单，之后按指定间隔刷新
+func OllamaInventoryWatcher(inventory *OllamaInventory, interval time.Duration) {
+	inventory.Refresh()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		inventory.Refresh()
+	}
+}
+
+// Get 获取最近一次的清单
+func (o *OllamaInventory) Get() models.OllamaInventoryResponse {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.snapshot
+}
+
+// Refresh 拉取所有实例的模型清单
+func (o *OllamaInventory) Refresh() models.OllamaInventoryResponse {
+	timeout := configs.IntervalFromMs(o.cfg.OllamaTimeoutMs, time.Second)
+	lists := make([]*ollama.ListResponse, len(o.cfg.OllamaListens))
+	instances := make([]models.OllamaInventoryInstance, len(o.cfg.OllamaListens))
+	forEachOllamaInstance(o.cfg, func(i int, host string) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		resp, err := ollama.NewClient(host).Tags(ctx)
+		instances[i] = models.OllamaInventoryInstance{Server: host, Status: err == nil}
+		if err != nil {
+			instances[i].Error = err.Error()
+			return
+		}
+		lists[i] = resp
+		instances[i].ModelCount = len(resp.Models)
+	})
+
+	snapshot := models.OllamaInventoryResponse{
+		Models:    BuildOllamaInventory(o.cfg.OllamaListens, lists),
+		Instances: instances,
+		UpdatedAt: time.Now().Unix(),
+	}
+	o.mu.Lock()
+	o.snapshot = snapshot
+	o.mu.Unlock()
+	return snapshot
+}
+
+// BuildOllamaInventory 按模型名称汇总各实例的 /api/tags 结果，lists 中为 nil 的实例视为离线，不参与缺失判断
+func BuildOllamaInventory(servers []string, lists []*ollama.ListResponse) []models.OllamaInventoryModel {
+	byName := map[string]*models.OllamaInventoryModel{}
+	for i, list := range lists {
+		if list == nil {
+			continue
+		}
+		for _, m := range list.Models {
+			item, ok := byName[m.Name]
+			if !ok {
+				item = &models.OllamaInventoryModel{
+					Name:              m.Name,
+					Size:              m.Size,
+					Digest:            m.Digest,
+					Family:            m.Details.Family,
+					ParameterSize:     m.Details.ParameterSize,
+					QuantizationLevel: m.Details.QuantizationLevel,
+					Format:            m.Details.Format,
+				}
+				byName[m.Name] = item
+			}
+			if m.Digest != item.Digest {
+				item.Drift = true
+			}
+			item.Instances = append(item.Instances, models.OllamaInventoryCopy{
+				Server:     servers[i],
+				Digest:     m.Digest,
+				Size:       m.Size,
+				ModifiedAt: m.ModifiedAt.Unix(),
+			})
+		}
+	}
+
+	result := make([]models.OllamaInventoryModel, 0, len(byName))
+	for _, item := range byName {
+		item.Missing = make([]string, 0)
+		for i, list := range lists {
+			if list == nil {
+				continue
+			}
+			found := false
+			for _, c := range item.Instances {
+				if c.Server == servers[i] {
+					found = true
+					break
+				}
+			}
+			if !found {
+				item.Missing = append(item.Missing, servers[i])
+			}
+		}
+		result = append(result, *item)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
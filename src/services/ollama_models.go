@@ -0,0 +1,284 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/LanceLRQ/ollama-watchdog/configs"
+	"github.com/LanceLRQ/ollama-watchdog/models"
+	"github.com/LanceLRQ/ollama-watchdog/ollama"
+)
+
+const (
+	OllamaPullRunning   = "running"
+	OllamaPullSuccess   = "success"
+	OllamaPullFailed    = "failed"
+	OllamaPullCancelled = "cancelled"
+)
+
+// 已结束的拉取任务保留时长
+const ollamaPullRetention = time.Hour
+
+// ResolveOllamaServer 校验目标实例是否在 ollama_listens 中，为空时使用第一个实例
+func ResolveOllamaServer(cfg *configs.ServerConfigStruct, server string) (string, error) {
+	if server == "" {
+		if cfg.OllamaListen == "" {
+			return "", fmt.Errorf("没有配置ollama监听地址")
+		}
+		return cfg.OllamaListen, nil
+	}
+	if !slices.Contains(cfg.OllamaListens, server) {
+		return "", fmt.Errorf("未配置的ollama实例：%s", server)
+	}
+	return server, nil
+}
+
+// DeleteOllamaModel 删除实例上的模型
+func DeleteOllamaModel(server string, model string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	return ollama.NewClient(server).Delete(ctx, &ollama.DeleteRequest{Model: model})
+}
+
+// CopyOllamaModel 在实例上复制模型
+func CopyOllamaModel(server string, source string, destination string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	return ollama.NewClient(server).Copy(ctx, &ollama.CopyRequest{Source: source, Destination: destination})
+}
+
+// UnloadOllamaModel 通过发送 keep_alive 为 0 的生成请求卸载模型，不依赖 ollama 命令行
+func UnloadOllamaModel(server string, model string, timeout time.Duration) models.OllamaUnloadResult {
+	result := models.OllamaUnloadResult{Server: server, Model: model}
+	client := ollama.NewClient(server)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	ps, err := client.PS(ctx)
+	if err != nil {
+		return unloadErrorResult(result, err)
+	}
+	// 与 ollama stop 一致，未指定标签时按 :latest 匹配
+	loaded := findLoadedModel(ps, normalizeOllamaModelName(model))
+	if loaded == nil {
+		result.Result = models.OllamaUnloadNotLoaded
+		result.Message = "model is not loaded"
+		return result
+	}
+
+	stream := false
+	err = client.Generate(ctx, &ollama.GenerateRequest{Model: loaded.Name, Stream: &stream, KeepAlive: 0}, func(ollama.GenerateResponse) error {
+		return nil
+	})
+	if err != nil {
+		return unloadErrorResult(result, err)
+	}
+	result.Result = models.OllamaUnloadSuccess
+	return result
+}
+
+func unloadErrorResult(result models.OllamaUnloadResult, err error) models.OllamaUnloadResult {
+	result.Message = err.Error()
+	switch {
+	case ollama.IsUnreachable(err):
+		result.Result = models.OllamaUnloadUnreachable
+	case ollama.IsNotFound(err):
+		result.Result = models.OllamaUnloadNotLoaded
+	default:
+		result.Result = models.OllamaUnloadFailed
+	}
+	return result
+}
+
+// OllamaPullManager 管理后台模型拉取任务，任务与发起请求的连接无关，断开后继续执行，可列出和取消
+type OllamaPullManager struct {
+	mu     sync.Mutex
+	nextID uint64
+	jobs   map[string]*ollamaPullTask
+	onDone func(models.OllamaPullJob)
+}
+
+type ollamaPullTask struct {
+	job         models.OllamaPullJob
+	cancel      context.CancelFunc
+	subscribers map[chan models.OllamaPullJob]struct{}
+}
+
+// NewOllamaPullManager onDone 在任务结束时调用（可为nil）
+func NewOllamaPullManager(onDone func(models.OllamaPullJob)) *OllamaPullManager {
+	return &OllamaPullManager{
+		jobs:   map[string]*ollamaPullTask{},
+		onDone: onDone,
+	}
+}
+
+// Start 创建拉取任务并在后台执行
+func (m *OllamaPullManager) Start(server string, model string, insecure bool) models.OllamaPullJob {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	m.mu.Lock()
+	m.prune()
+	m.nextID++
+	task := &ollamaPullTask{
+		job: models.OllamaPullJob{
+			ID:        strconv.FormatUint(m.nextID, 10),
+			Server:    server,
+			Model:     model,
+			Status:    OllamaPullRunning,
+			StartedAt: time.Now().Unix(),
+		},
+		cancel:      cancel,
+		subscribers: map[chan models.OllamaPullJob]struct{}{},
+	}
+	m.jobs[task.job.ID] = task
+	job := task.job
+	m.mu.Unlock()
+
+	go m.run(ctx, task, insecure)
+	return job
+}
+
+func (m *OllamaPullManager) run(ctx context.Context, task *ollamaPullTask, insecure bool) {
+	client := ollama.NewClient(task.job.Server)
+	err := client.Pull(ctx, &ollama.PullRequest{Model: task.job.Model, Insecure: insecure}, func(progress ollama.ProgressResponse) error {
+		m.update(task, func(job *models.OllamaPullJob) {
+			job.Message = progress.Status
+			job.Digest = progress.Digest
+			job.Total = progress.Total
+			job.Completed = progress.Completed
+		})
+		return nil
+	})
+
+	m.mu.Lock()
+	task.job.FinishedAt = time.Now().Unix()
+	switch {
+	case ctx.Err() != nil:
+		task.job.Status = OllamaPullCancelled
+	case err != nil:
+		task.job.Status = OllamaPullFailed
+		task.job.Error = err.Error()
+	default:
+		task.job.Status = OllamaPullSuccess
+	}
+	job := task.job
+	for ch := range task.subscribers {
+		// 最终状态必须送达：缓冲区已满时丢弃最旧的一条进度再发送，
+		// 发送只在持有锁时进行，取出一条后一定有空位
+		select {
+		case ch <- job:
+		default:
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- job
+		}
+		close(ch)
+	}
+	task.subscribers = nil
+	m.mu.Unlock()
+
+	if m.onDone != nil {
+		m.onDone(job)
+	}
+}
+
+// update 修改任务状态并推送给订阅者
+func (m *OllamaPullManager) update(task *ollamaPullTask, fn func(job *models.OllamaPullJob)) models.OllamaPullJob {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fn(&task.job)
+	for ch := range task.subscribers {
+		// 订阅者处理不过来时丢弃中间进度
+		select {
+		case ch <- task.job:
+		default:
+		}
+	}
+	return task.job
+}
+
+// Get 获取任务
+func (m *OllamaPullManager) Get(id string) (models.OllamaPullJob, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	task, ok := m.jobs[id]
+	if !ok {
+		return models.OllamaPullJob{}, false
+	}
+	return task.job, true
+}
+
+// List 列出所有任务，按开始时间倒序
+func (m *OllamaPullManager) List() []models.OllamaPullJob {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prune()
+	list := make([]models.OllamaPullJob, 0, len(m.jobs))
+	for _, task := range m.jobs {
+		list = append(list, task.job)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, _ := strconv.ParseUint(list[i].ID, 10, 64)
+		b, _ := strconv.ParseUint(list[j].ID, 10, 64)
+		return a > b
+	})
+	return list
+}
+
+// Cancel 取消正在执行的任务
+func (m *OllamaPullManager) Cancel(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	task, ok := m.jobs[id]
+	if !ok {
+		return fmt.Errorf("任务不存在：%s", id)
+	}
+	if task.job.Status != OllamaPullRunning {
+		return fmt.Errorf("任务已结束：%s", task.job.Status)
+	}
+	task.cancel()
+	return nil
+}
+
+// Subscribe 订阅任务进度，返回的通道先收到当前状态，任务结束后关闭
+func (m *OllamaPullManager) Subscribe(id string) (<-chan models.OllamaPullJob, func(), error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	task, ok := m.jobs[id]
+	if !ok {
+		return nil, nil, fmt.Errorf("任务不存在：%s", id)
+	}
+	ch := make(chan models.OllamaPullJob, 16)
+	ch <- task.job
+	if task.subscribers == nil {
+		close(ch)
+		return ch, func() {}, nil
+	}
+	task.subscribers[ch] = struct{}{}
+	unsubscribe := func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if _, ok := task.subscribers[ch]; ok {
+			delete(task.subscribers, ch)
+			close(ch)
+		}
+	}
+	return ch, unsubscribe, nil
+}
+
+// prune 清理过期的已结束任务，调用方需持有锁
+func (m *OllamaPullManager) prune() {
+	now := time.Now().Unix()
+	for id, task := range m.jobs {
+		if task.job.FinishedAt > 0 && now-task.job.FinishedAt > int64(ollamaPullRetention.Seconds()) {
+			delete(m.jobs, id)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/LanceLRQ/ollama-watchdog/models"
+)
+
+func TestOllamaPullManagerFinalState(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		// 进度远多于订阅通道的缓冲
+		for i := 0; i < 100; i++ {
+			fmt.Fprintf(w, `{"status":"pulling","total":100,"completed":%d}`+"\n", i)
+		}
+		fmt.Fprintln(w, `{"status":"success"}`)
+	}))
+	defer srv.Close()
+
+	done := make(chan models.OllamaPullJob, 1)
+	manager := NewOllamaPullManager(func(job models.OllamaPullJob) { done <- job })
+	job := manager.Start(srv.URL, "qwen2.5:0.5b", false)
+
+	// 订阅后不读取，直到任务结束
+	progress, unsubscribe, err := manager.Subscribe(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unsubscribe()
+	close(release)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("pull did not finish")
+	}
+
+	var last models.OllamaPullJob
+	for update := range progress {
+		last = update
+	}
+	if last.Status != OllamaPullSuccess || last.FinishedAt == 0 {
+		t.Errorf("last update = %+v, want final success", last)
+	}
+}
+
+func TestOllamaPullManagerCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"status":"pulling manifest"}`)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	manager := NewOllamaPullManager(nil)
+	job := manager.Start(srv.URL, "llama3:70b", false)
+	progress, unsubscribe, err := manager.Subscribe(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unsubscribe()
+
+	// 等待收到第一条进度后取消
+	for update := range progress {
+		if update.Message == "pulling manifest" {
+			break
+		}
+	}
+	if err := manager.Cancel(job.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	var last models.OllamaPullJob
+	timeout := time.After(5 * time.Second)
+	for closed := false; !closed; {
+		select {
+		case update, ok := <-progress:
+			if !ok {
+				closed = true
+				break
+			}
+			last = update
+		case <-timeout:
+			t.Fatal("progress channel not closed after cancel")
+		}
+	}
+	if last.Status != OllamaPullCancelled {
+		t.Errorf("last update = %+v, want cancelled", last)
+	}
+	if err := manager.Cancel(job.ID); err == nil {
+		t.Error("cancelling a finished job should fail")
+	}
+}
+
+func TestUnloadOllamaModel(t *testing.T) {
+	var unloaded []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/ps":
+			fmt.Fprintln(w, `{"models":[{"name":"llama3:latest","model":"llama3:latest"},{"name":"library/qwen2.5:7b","model":"library/qwen2.5:7b"}]}`)
+		case "/api/generate":
+			var req struct {
+				Model     string `json:"model"`
+				KeepAlive *int   `json:"keep_alive"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.KeepAlive == nil || *req.KeepAlive != 0 {
+				http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
+				return
+			}
+			unloaded = append(unloaded, req.Model)
+			fmt.Fprintln(w, `{"done":true,"done_reason":"unload"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		model    string
+		result   string
+		unloaded string
+	}{
+		{"llama3", models.OllamaUnloadSuccess, "llama3:latest"},
+		{"llama3:latest", models.OllamaUnloadSuccess, "llama3:latest"},
+		{"library/qwen2.5:7b", models.OllamaUnloadSuccess, "library/qwen2.5:7b"},
+		{"qwen2.5", models.OllamaUnloadNotLoaded, ""},
+		{"llama3:70b", models.OllamaUnloadNotLoaded, ""},
+	}
+	for _, tt := range tests {
+		unloaded = nil
+		result := UnloadOllamaModel(srv.URL, tt.model, 5*time.Second)
+		if result.Result != tt.result {
+			t.Errorf("%s: result = %s (%s), want %s", tt.model, result.Result, result.Message, tt.result)
+		}
+		if tt.unloaded != "" && (len(unloaded) != 1 || unloaded[0] != tt.unloaded) {
+			t.Errorf("%s: unloaded %v, want %s", tt.model, unloaded, tt.unloaded)
+		}
+		if tt.unloaded == "" && len(unloaded) != 0 {
+			t.Errorf("%s: unexpected unload request %v", tt.model, unloaded)
+		}
+	}
+}
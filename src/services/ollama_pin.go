@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LanceLRQ/ollama-watchdog/configs"
+	"github.com/LanceLRQ/ollama-watchdog/models"
+	"github.com/LanceLRQ/ollama-watchdog/ollama"
+)
+
+const (
+	// 加载失败后的重试间隔，逐次翻倍
+	ollamaPinMinBackoff = 30 * time.Second
+	ollamaPinMaxBackoff = 10 * time.Minute
+	// 常驻模型的过期时间不足该值时重新发送 keep_alive
+	ollamaPinRefreshBefore = 24 * time.Hour
+	// 加载模型的超时时间（大模型冷启动较慢）
+	ollamaPinLoadTimeout = 10 * time.Minute
+)
+
+// OllamaPinner 保证配置中的常驻模型始终加载在指定实例上，服务重启或模型被换出后自动重新加载
+type OllamaPinner struct {
+	cfg *configs.ServerConfigStruct
+
+	mu    sync.Mutex
+	state map[string]*ollamaPinRecord // server|model -> 状态
+}
+
+type ollamaPinRecord struct {
+	server  string
+	state   models.OllamaPinState
+	backoff time.Duration
+	nextTry time.Time
+}
+
+func NewOllamaPinner(cfg *configs.ServerConfigStruct) *OllamaPinner {
+	p := &OllamaPinner{cfg: cfg, state: map[string]*ollamaPinRecord{}}
+	for _, pin := range cfg.OllamaPins {
+		server := pin.Server
+		if server == "" {
+			server = cfg.OllamaListen
+		}
+		keepAlive := pin.KeepAlive
+		if keepAlive == "" {
+			keepAlive = "-1"
+		}
+		model := normalizeOllamaModelName(pin.Model)
+		p.state[server+"|"+model] = &ollamaPinRecord{
+			server: server,
+			state:  models.OllamaPinState{Model: model, KeepAlive: keepAlive},
+		}
+	}
+	return p
+}
+
+// IsPinned 判断模型是否为常驻模型
+func (p *OllamaPinner) IsPinned(server string, model string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, ok := p.state[server+"|"+normalizeOllamaModelName(model)]
+	return ok
+}
+
+// Enforce 根据最新的 /api/ps 结果检查常驻模型，未加载或即将过期的在后台重新加载
+func (p *OllamaPinner) Enforce(response models.OllamaPSResponse) {
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, record := range p.state {
+		instance := findOllamaInstance(response, record.server)
+		// 实例离线时不做处理，等待恢复
+		if instance == nil || !instance.Status || instance.Data == nil {
+			record.state.Loaded = false
+			continue
+		}
+		model := findLoadedModel(instance.Data, record.state.Model)
+		record.state.Loaded = model != nil
+		if record.state.Loading || now.Before(record.nextTry) {
+			continue
+		}
+		if model != nil && (record.state.KeepAlive != "-1" || time.Until(model.ExpiresAt) > ollamaPinRefreshBefore) {
+			continue
+		}
+		record.state.Loading = true
+		record.state.LastAttempt = now.Unix()
+		go p.load(record)
+	}
+}
+
+func (p *OllamaPinner) load(record *ollamaPinRecord) {
+	ctx, cancel := context.WithTimeout(context.Background(), ollamaPinLoadTimeout)
+	defer cancel()
+
+	stream := false
+	err := ollama.NewClient(record.server).Generate(ctx, &ollama.GenerateRequest{
+		Model:     record.state.Model,
+		Stream:    &stream,
+		KeepAlive: parseKeepAlive(record.state.KeepAlive),
+	}, func(ollama.GenerateResponse) error {
+		return nil
+	})
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	record.state.Loading = false
+	if err != nil {
+		record.state.LastError = err.Error()
+		if record.backoff == 0 {
+			record.backoff = ollamaPinMinBackoff
+		} else {
+			record.backoff = min(record.backoff*2, ollamaPinMaxBackoff)
+		}
+		record.nextTry = time.Now().Add(record.backoff)
+		fmt.Printf("Failed to load pinned model %s on %s: %s, retry in %s\n", record.state.Model, record.server, err.Error(), record.backoff)
+		return
+	}
+	record.state.LastError = ""
+	record.state.Loaded = true
+	record.backoff = 0
+	// 加载完成后 /api/ps 需要一点时间反映出来，避免立即重复加载
+	record.nextTry = time.Now().Add(ollamaPinMinBackoff)
+	fmt.Printf("Pinned model %s loaded on %s\n", record.state.Model, record.server)
+}
+
+// Annotate 将常驻模型状态附加到 /api/ps 结果中
+func (p *OllamaPinner) Annotate(response *models.OllamaPSResponse) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i := range response.Data {
+		instance := &response.Data[i]
+		instance.Pins = nil
+		for _, record := range p.state {
+			if record.server == instance.Server {
+				instance.Pins = append(instance.Pins, record.state)
+			}
+		}
+	}
+}
+
+func findOllamaInstance(response models.OllamaPSResponse, server string) *models.OllamaInstancePS {
+	for i := range response.Data {
+		if response.Data[i].Server == server {
+			return &response.Data[i]
+		}
+	}
+	return nil
+}
+
+func findLoadedModel(ps *ollama.PSResponse, model string) *ollama.ProcessModel {
+	for i := range ps.Models {
+		if normalizeOllamaModelName(ps.Models[i].Name) == model || normalizeOllamaModelName(ps.Models[i].Model) == model {
+			return &ps.Models[i]
+		}
+	}
+	return nil
+}
+
+// normalizeOllamaModelName 未指定标签的模型名称补全为 :latest
+func normalizeOllamaModelName(name string) string {
+	name = strings.TrimSpace(name)
+	if name != "" && !strings.Contains(name[strings.LastIndex(name, "/")+1:], ":") {
+		return name + ":latest"
+	}
+	return name
+}
+
+// parseKeepAlive 数字按秒处理，其余（如 "24h"）原样交给 Ollama 解析
+func parseKeepAlive(value string) any {
+	if n, err := strconv.Atoi(value); err == nil {
+		return n
+	}
+	return value
+}
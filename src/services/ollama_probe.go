@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/LanceLRQ/ollama-watchdog/configs"
+	"github.com/LanceLRQ/ollama-watchdog/models"
+	"github.com/LanceLRQ/ollama-watchdog/ollama"
+	"github.com/dgraph-io/badger/v4"
+)
+
+// OllamaProbeWatcher 定期执行 ollama_probes 中配置的合成探测，每轮所有探测完成后回调一次
+func OllamaProbeWatcher(cfg *configs.ServerConfigStruct, callback func(models.OllamaProbeResponse)) {
+	if len(cfg.OllamaProbes) == 0 {
+		return
+	}
+	ticker := time.NewTicker(configs.IntervalFromMs(cfg.ProbeIntervalMs, time.Minute))
+	defer ticker.Stop()
+
+	for range ticker.C {
+		callback(RunOllamaProbes(cfg))
+	}
+}
+
+// RunOllamaProbes 并发执行所有合成探测
+func RunOllamaProbes(cfg *configs.ServerConfigStruct) models.OllamaProbeResponse {
+	type target struct {
+		server string
+		probe  configs.OllamaProbeConfig
+	}
+	var targets []target
+	for _, probe := range cfg.OllamaProbes {
+		if probe.Model == "" {
+			continue
+		}
+		if probe.Server != "" {
+			targets = append(targets, target{server: probe.Server, probe: probe})
+			continue
+		}
+		for _, server := range cfg.OllamaListens {
+			targets = append(targets, target{server: server, probe: probe})
+		}
+	}
+
+	timeout := configs.IntervalFromMs(cfg.ProbeTimeoutMs, 30*time.Second)
+	results := make([]models.OllamaProbeResult, len(targets))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = RunOllamaProbe(t.server, t.probe, timeout)
+		}()
+	}
+	wg.Wait()
+
+	now := time.Now()
+	return models.OllamaProbeResponse{
+		Results:     results,
+		Timestamp:   now.Unix(),
+		TimestampMs: now.UnixMilli(),
+	}
+}
+
+// RunOllamaProbe 以流式 /api/generate 请求模型，记录首 token 耗时、加载耗时与生成速度
+func RunOllamaProbe(server string, probe configs.OllamaProbeConfig, timeout time.Duration) models.OllamaProbeResult {
+	result := models.OllamaProbeResult{Server: server, Model: probe.Model}
+
+	prompt := probe.Prompt
+	if prompt == "" {
+		prompt = "Hi"
+	}
+	numPredict := probe.NumPredict
+	if numPredict <= 0 {
+		numPredict = 16
+	}
+	stream := true
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	var final ollama.GenerateResponse
+	err := ollama.NewClient(server).Generate(ctx, &ollama.GenerateRequest{
+		Model:   probe.Model,
+		Prompt:  prompt,
+		Stream:  &stream,
+		Options: map[string]any{"num_predict": numPredict},
+	}, func(resp ollama.GenerateResponse) error {
+		if result.TTFTMs == 0 && (resp.Response != "" || resp.Done) {
+			result.TTFTMs = max(1, time.Since(start).Milliseconds())
+		}
+		if resp.Done {
+			final = resp
+		}
+		return nil
+	})
+	result.TotalDurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Unreachable = ollama.IsUnreachable(err) || errors.Is(err, context.DeadlineExceeded)
+		if errors.Is(err, context.DeadlineExceeded) {
+			result.Error = fmt.Sprintf("timeout after %s", timeout)
+		} else {
+			result.Error = err.Error()
+		}
+		return result
+	}
+	if !final.Done {
+		result.Error = "generation ended without a final response"
+		return result
+	}
+
+	result.Success = true
+	result.LoadDurationMs = time.Duration(final.LoadDuration).Milliseconds()
+	result.PromptEvalCount = final.PromptEvalCount
+	result.EvalCount = final.EvalCount
+	if final.EvalDuration > 0 {
+		result.TokensPerSec = float64(final.EvalCount) / time.Duration(final.EvalDuration).Seconds()
+	}
+	return result
+}
+
+func SaveProbeSampleToDB(db *badger.DB, response models.OllamaProbeResponse, retention time.Duration) {
+	if err := saveSample(db, "probe", response.TimestampMs, retention, response); err != nil {
+		fmt.Printf("%s\n", err.Error())
+	}
+}
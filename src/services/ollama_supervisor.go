@@ -0,0 +1,209 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/LanceLRQ/ollama-watchdog/configs"
+	"github.com/LanceLRQ/ollama-watchdog/models"
+	"github.com/LanceLRQ/ollama-watchdog/utils"
+)
+
+const (
+	OllamaRestartSuccess = "success"
+	OllamaRestartFailed  = "failed"
+	OllamaRestartSkipped = "skipped"
+)
+
+const (
+	// 两次自动重启之间的最小间隔，逐次翻倍
+	ollamaRestartMinBackoff = 30 * time.Second
+	ollamaRestartMaxBackoff = 30 * time.Minute
+	// 保留的重启记录条数
+	ollamaRestartLogSize = 200
+)
+
+// OllamaSupervisor 当实例在 OllamaHealthTracker 中持续处于 down 状态超过指定时长时，
+// 重启 ollama_services 中对应的系统服务
+//
+// 两次重启之间按指数退避，每小时重启次数超过上限后熔断，直到最早的一次重启移出一小时窗口
+type OllamaSupervisor struct {
+	cfg *configs.ServerConfigStruct
+	// 执行重启的函数，测试时替换
+	restartService func(typeName string, serviceName string) error
+
+	mu        sync.Mutex
+	instances map[string]*ollamaSupervisedInstance
+	records   []models.OllamaRestartRecord
+}
+
+type ollamaSupervisedInstance struct {
+	downSince   time.Time
+	downReason  string
+	restarting  bool
+	backoff     time.Duration
+	nextRestart time.Time
+	restarts    []time.Time
+	circuitOpen bool
+}
+
+func NewOllamaSupervisor(cfg *configs.ServerConfigStruct, tracker *OllamaHealthTracker) *OllamaSupervisor {
+	s := &OllamaSupervisor{
+		cfg:            cfg,
+		restartService: utils.RestartServiceProcess,
+		instances:      map[string]*ollamaSupervisedInstance{},
+	}
+	tracker.Subscribe(s.onHealthEvent)
+	return s
+}
+
+// onHealthEvent 进入 down 时开始计时，离开 down 时清除计时并重置退避
+func (s *OllamaSupervisor) onHealthEvent(event models.OllamaHealthEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.instance(event.Server)
+	if event.To == models.OllamaHealthDown {
+		state.downSince = time.Unix(event.Timestamp, 0)
+		state.downReason = event.Reason
+		return
+	}
+	if !state.downSince.IsZero() {
+		state.backoff = 0
+	}
+	state.downSince = time.Time{}
+	state.downReason = ""
+}
+
+// Check 实例 down 的时长超过宽限期后重启服务，由 ps 轮询驱动
+func (s *OllamaSupervisor) Check(response models.OllamaPSResponse) {
+	if !s.cfg.SupervisorEnabled {
+		return
+	}
+	now := time.Now()
+	window := time.Duration(s.cfg.SupervisorUnhealthySec) * time.Second
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, instance := range response.Data {
+		state := s.instance(instance.Server)
+		if state.downSince.IsZero() {
+			continue
+		}
+		if instance.ServiceName == "" || state.restarting || now.Sub(state.downSince) < window || now.Before(state.nextRestart) {
+			continue
+		}
+
+		// 熔断：只统计最近一小时内的重启
+		restarts := state.restarts[:0]
+		for _, at := range state.restarts {
+			if now.Sub(at) < time.Hour {
+				restarts = append(restarts, at)
+			}
+		}
+		state.restarts = restarts
+		limit := s.cfg.SupervisorMaxRestartsPerHour
+		if limit > 0 && len(state.restarts) >= limit {
+			if !state.circuitOpen {
+				state.circuitOpen = true
+				s.appendRecord(models.OllamaRestartRecord{
+					Timestamp:   now.Unix(),
+					Server:      instance.Server,
+					ServiceName: instance.ServiceName,
+					Reason:      fmt.Sprintf("circuit open: %d restarts in the last hour", len(state.restarts)),
+					Result:      OllamaRestartSkipped,
+				})
+				fmt.Printf("Ollama service %s restart skipped: circuit open\n", instance.ServiceName)
+			}
+			continue
+		}
+		state.circuitOpen = false
+
+		if state.backoff == 0 {
+			state.backoff = ollamaRestartMinBackoff
+		} else {
+			state.backoff = min(state.backoff*2, ollamaRestartMaxBackoff)
+		}
+		state.nextRestart = now.Add(state.backoff)
+		state.restarts = append(state.restarts, now)
+		state.restarting = true
+		reason := fmt.Sprintf("down for %s", now.Sub(state.downSince).Round(time.Second))
+		if state.downReason != "" {
+			reason += ": " + state.downReason
+		}
+		go s.restart(instance.Server, instance.ServiceName, reason)
+	}
+}
+
+func (s *OllamaSupervisor) restart(server string, serviceName string, reason string) {
+	err := s.restartService("restart", serviceName)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.instance(server)
+	state.restarting = false
+	// 重启期间仍未恢复时重新计时，给服务启动留出时间
+	if !state.downSince.IsZero() {
+		state.downSince = time.Now()
+	}
+	s.recordResult(server, serviceName, reason, err)
+}
+
+// RecordManual 记录通过界面手动执行的重启
+func (s *OllamaSupervisor) RecordManual(serviceName string, typeName string, err error) {
+	server := ""
+	for i, name := range s.cfg.OllamaServices {
+		if name == serviceName && i < len(s.cfg.OllamaListens) {
+			server = s.cfg.OllamaListens[i]
+			break
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recordResult(server, serviceName, "manual "+typeName, err)
+}
+
+// recordResult 调用方需持有锁
+func (s *OllamaSupervisor) recordResult(server string, serviceName string, reason string, err error) {
+	record := models.OllamaRestartRecord{
+		Timestamp:   time.Now().Unix(),
+		Server:      server,
+		ServiceName: serviceName,
+		Reason:      reason,
+		Result:      OllamaRestartSuccess,
+	}
+	if err != nil {
+		record.Result = OllamaRestartFailed
+		record.Error = err.Error()
+	}
+	fmt.Printf("Ollama service %s restart (%s): %s %s\n", serviceName, reason, record.Result, record.Error)
+	s.appendRecord(record)
+}
+
+// appendRecord 调用方需持有锁
+func (s *OllamaSupervisor) appendRecord(record models.OllamaRestartRecord) {
+	s.records = append(s.records, record)
+	if len(s.records) > ollamaRestartLogSize {
+		s.records = s.records[len(s.records)-ollamaRestartLogSize:]
+	}
+}
+
+// Records 获取重启记录，按时间倒序
+func (s *OllamaSupervisor) Records() []models.OllamaRestartRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]models.OllamaRestartRecord, len(s.records))
+	for i, record := range s.records {
+		list[len(s.records)-1-i] = record
+	}
+	return list
+}
+
+func (s *OllamaSupervisor) instance(server string) *ollamaSupervisedInstance {
+	state, ok := s.instances[server]
+	if !ok {
+		state = &ollamaSupervisedInstance{}
+		s.instances[server] = state
+	}
+	return state
+}
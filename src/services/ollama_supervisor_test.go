@@ -0,0 +1,195 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LanceLRQ/ollama-watchdog/configs"
+	"github.com/LanceLRQ/ollama-watchdog/models"
+)
+
+func TestOllamaSupervisorFollowsHealthTracker(t *testing.T) {
+	cfg := configs.GetDefaultServerConfig()
+	cfg.SupervisorEnabled = true
+	cfg.SupervisorUnhealthySec = 60
+	cfg.SupervisorMaxRestartsPerHour = 0
+	cfg.OllamaFailThreshold = 2
+	tracker := NewOllamaHealthTracker(&cfg)
+	supervisor := NewOllamaSupervisor(&cfg, tracker)
+	restarted := make(chan string, 4)
+	supervisor.restartService = func(typeName string, serviceName string) error {
+		restarted <- serviceName
+		return nil
+	}
+
+	const server = "http://127.0.0.1:11434"
+	offline := models.OllamaPSResponse{Data: []models.OllamaInstancePS{
+		{Server: server, ServiceName: "ollama", Status: false, Error: "connection refused"},
+	}}
+	expectRestart := func(want bool) {
+		t.Helper()
+		select {
+		case name := <-restarted:
+			if !want {
+				t.Fatalf("unexpected restart of %s", name)
+			}
+		case <-time.After(200 * time.Millisecond):
+			if want {
+				t.Fatal("expected a restart")
+			}
+		}
+	}
+	ageDown := func(d time.Duration) {
+		supervisor.mu.Lock()
+		supervisor.instance(server).downSince = time.Now().Add(-d)
+		supervisor.mu.Unlock()
+	}
+
+	// 单次失败只是 degraded，即使 ps 报告离线也不开始计时
+	failure := errors.New("connection refused")
+	tracker.Observe(server, 0, failure)
+	supervisor.Check(offline)
+	expectRestart(false)
+
+	// 进入 down 后在宽限期内不重启
+	tracker.Observe(server, 0, failure)
+	supervisor.Check(offline)
+	expectRestart(false)
+
+	// down 超过宽限期后重启
+	ageDown(2 * time.Minute)
+	supervisor.Check(offline)
+	expectRestart(true)
+	// 等待重启协程写入记录
+	var records []models.OllamaRestartRecord
+	for deadline := time.Now().Add(time.Second); len(records) == 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		records = supervisor.Records()
+	}
+	if len(records) != 1 || records[0].Result != OllamaRestartSuccess {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+
+	// 恢复后清除计时，旧的 down 不再触发重启
+	tracker.Observe(server, 0, nil)
+	supervisor.mu.Lock()
+	state := supervisor.instance(server)
+	if !state.downSince.IsZero() || state.backoff != 0 {
+		t.Errorf("state not reset after recovery: %+v", state)
+	}
+	supervisor.mu.Unlock()
+	supervisor.Check(offline)
+	expectRestart(false)
+}
+
+func TestOllamaSupervisorBackoffAndCircuitBreaker(t *testing.T) {
+	cfg := configs.GetDefaultServerConfig()
+	cfg.SupervisorEnabled = true
+	cfg.SupervisorUnhealthySec = 60
+	cfg.SupervisorMaxRestartsPerHour = 2
+	cfg.OllamaFailThreshold = 1
+	tracker := NewOllamaHealthTracker(&cfg)
+	supervisor := NewOllamaSupervisor(&cfg, tracker)
+	restarted := make(chan string, 4)
+	supervisor.restartService = func(typeName string, serviceName string) error {
+		restarted <- serviceName
+		return nil
+	}
+
+	const server = "http://127.0.0.1:11434"
+	offline := models.OllamaPSResponse{Data: []models.OllamaInstancePS{
+		{Server: server, ServiceName: "ollama", Status: false, Error: "connection refused"},
+	}}
+	expectRestart := func(want bool) {
+		t.Helper()
+		select {
+		case name := <-restarted:
+			if !want {
+				t.Fatalf("unexpected restart of %s", name)
+			}
+		case <-time.After(200 * time.Millisecond):
+			if want {
+				t.Fatal("expected a restart")
+			}
+		}
+	}
+	// 让 down 超过宽限期；skipBackoff 为 true 时同时跳过退避等待
+	ageDown := func(skipBackoff bool) {
+		supervisor.mu.Lock()
+		defer supervisor.mu.Unlock()
+		state := supervisor.instance(server)
+		state.downSince = time.Now().Add(-2 * time.Minute)
+		if skipBackoff {
+			state.nextRestart = time.Time{}
+		}
+	}
+	waitForRecords := func(n int) []models.OllamaRestartRecord {
+		t.Helper()
+		var records []models.OllamaRestartRecord
+		for deadline := time.Now().Add(time.Second); len(records) < n && time.Now().Before(deadline); {
+			time.Sleep(10 * time.Millisecond)
+			records = supervisor.Records()
+		}
+		if len(records) != n {
+			t.Fatalf("got %d records, want %d: %+v", len(records), n, records)
+		}
+		return records
+	}
+	backoff := func() time.Duration {
+		supervisor.mu.Lock()
+		defer supervisor.mu.Unlock()
+		return supervisor.instance(server).backoff
+	}
+
+	tracker.Observe(server, 0, errors.New("connection refused"))
+	ageDown(false)
+	supervisor.Check(offline)
+	expectRestart(true)
+	waitForRecords(1)
+	if got := backoff(); got != ollamaRestartMinBackoff {
+		t.Fatalf("backoff = %s, want %s", got, ollamaRestartMinBackoff)
+	}
+
+	// 退避期内即使 down 超过宽限期也不重启
+	ageDown(false)
+	supervisor.Check(offline)
+	expectRestart(false)
+
+	// 退避结束后再次重启，退避时间翻倍
+	ageDown(true)
+	supervisor.Check(offline)
+	expectRestart(true)
+	waitForRecords(2)
+	if got := backoff(); got != 2*ollamaRestartMinBackoff {
+		t.Fatalf("backoff = %s, want %s", got, 2*ollamaRestartMinBackoff)
+	}
+
+	// 一小时内的重启次数达到上限后熔断，只记录一次跳过
+	ageDown(true)
+	supervisor.Check(offline)
+	expectRestart(false)
+	ageDown(true)
+	supervisor.Check(offline)
+	expectRestart(false)
+	records := waitForRecords(3)
+	if records[0].Result != OllamaRestartSkipped || !strings.Contains(records[0].Reason, "circuit open") {
+		t.Fatalf("unexpected circuit breaker record: %+v", records[0])
+	}
+
+	// 最早的重启移出一小时窗口后恢复重启
+	supervisor.mu.Lock()
+	state := supervisor.instance(server)
+	state.restarts[0] = time.Now().Add(-time.Hour)
+	supervisor.mu.Unlock()
+	ageDown(true)
+	supervisor.Check(offline)
+	expectRestart(true)
+	waitForRecords(4)
+	supervisor.mu.Lock()
+	if state.circuitOpen {
+		t.Error("circuit still open after the window moved on")
+	}
+	supervisor.mu.Unlock()
+}
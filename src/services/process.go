@@ -0,0 +1,191 @@
+package services
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LanceLRQ/ollama-watchdog/models"
+	"github.com/LanceLRQ/ollama-watchdog/utils"
+)
+
+// 用户态时钟频率，Linux 上几乎总是100
+const clockTicksPerSecond = 100
+
+// 匹配 docker / containerd / podman / kubernetes 等 cgroup 路径中的容器ID
+var cgroupContainerIdRegexp = regexp.MustCompile(`(?:^|[/-])([0-9a-f]{64})(?:\.scope)?$`)
+
+// ProcessEnricher 从 /proc/<pid> 读取用户、命令行、启动时间、CPU、内存及 cgroup 信息补充到GPU进程上
+type ProcessEnricher struct {
+	ProcPath string // 默认为 /proc
+
+	mu       sync.Mutex
+	bootTime int64
+	prev     map[uint64]processCPUSample
+	users    map[string]string
+}
+
+type processCPUSample struct {
+	ticks uint64
+	at    time.Time
+}
+
+func NewProcessEnricher() *ProcessEnricher {
+	return &ProcessEnricher{
+		ProcPath: "/proc",
+		prev:     map[uint64]processCPUSample{},
+		users:    map[string]string{},
+	}
+}
+
+// Enrich 补充进程详情，读取失败的进程保持原样
+//
+// 同一进程使用多块GPU时会出现多次，只读取一次并复制到每条记录，避免后续记录的 CPU 利用率为0
+func (p *ProcessEnricher) Enrich(processes []models.GPUProcess) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.bootTime == 0 {
+		p.bootTime = p.readBootTime()
+	}
+	now := time.Now()
+	seen := map[uint64]*models.GPUProcess{}
+	for i := range processes {
+		proc := &processes[i]
+		if first, ok := seen[proc.PID]; ok {
+			proc.PPID = first.PPID
+			proc.User = first.User
+			proc.Cmdline = first.Cmdline
+			proc.StartTime = first.StartTime
+			proc.CPUUsed = first.CPUUsed
+			proc.RSS = first.RSS
+			proc.Unit = first.Unit
+			proc.ContainerId = first.ContainerId
+			continue
+		}
+		p.enrichOne(proc, now)
+		seen[proc.PID] = proc
+	}
+	for pid := range p.prev {
+		if _, ok := seen[pid]; !ok {
+			delete(p.prev, pid)
+		}
+	}
+}
+
+func (p *ProcessEnricher) enrichOne(proc *models.GPUProcess, now time.Time) {
+	dir := filepath.Join(p.ProcPath, strconv.FormatUint(proc.PID, 10))
+
+	if stat, err := os.ReadFile(filepath.Join(dir, "stat")); err == nil {
+		// 进程名（第2列）可能包含空格和括号，从最后一个右括号之后开始解析
+		if idx := strings.LastIndexByte(string(stat), ')'); idx > 0 {
+			fields := strings.Fields(string(stat)[idx+1:])
+			// fields[0] 为第3列 state
+			if len(fields) > 19 {
+				proc.PPID = utils.ParseUint(fields[1])
+				ticks := utils.ParseUint(fields[11]) + utils.ParseUint(fields[12])
+				if prev, ok := p.prev[proc.PID]; ok && ticks >= prev.ticks {
+					if elapsed := now.Sub(prev.at).Seconds(); elapsed > 0 {
+						proc.CPUUsed = float64(ticks-prev.ticks) / clockTicksPerSecond / elapsed * 100
+					}
+				}
+				p.prev[proc.PID] = processCPUSample{ticks: ticks, at: now}
+				if p.bootTime > 0 {
+					proc.StartTime = p.bootTime + int64(utils.ParseUint(fields[19])/clockTicksPerSecond)
+				}
+			}
+		}
+	}
+
+	if cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil {
+		proc.Cmdline = strings.TrimSpace(strings.ReplaceAll(string(cmdline), "\x00", " "))
+	}
+
+	if status, err := os.ReadFile(filepath.Join(dir, "status")); err == nil {
+		for _, line := range strings.Split(string(status), "\n") {
+			key, value, ok := strings.Cut(line, ":")
+			if !ok {
+				continue
+			}
+			switch key {
+			case "Uid":
+				if fields := strings.Fields(value); len(fields) > 0 {
+					proc.User = p.lookupUser(fields[0])
+				}
+			case "VmRSS":
+				// 单位为kB
+				proc.RSS = utils.ParseUint(value) / 1024
+			}
+		}
+	}
+
+	if cgroup, err := os.ReadFile(filepath.Join(dir, "cgroup")); err == nil {
+		proc.Unit, proc.ContainerId = ParseCgroup(string(cgroup))
+	}
+}
+
+// ParseCgroup 从 /proc/<pid>/cgroup 内容中解析 systemd unit 与容器ID
+//
+// cgroup v2 只有一行 "0::/system.slice/ollama.service"，v1 有多行，优先取 name=systemd 或 v2 的那一行
+func ParseCgroup(content string) (unit string, containerId string) {
+	var path string
+	for _, line := range strings.Split(strings.TrimSpace(content), "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[1] == "" || parts[1] == "name=systemd" {
+			path = parts[2]
+			break
+		}
+		if path == "" {
+			path = parts[2]
+		}
+	}
+	if path == "" {
+		return "", ""
+	}
+
+	elements := strings.Split(strings.Trim(path, "/"), "/")
+	for i := len(elements) - 1; i >= 0; i-- {
+		if m := cgroupContainerIdRegexp.FindStringSubmatch(elements[i]); m != nil {
+			return "", m[1][:12]
+		}
+	}
+	for i := len(elements) - 1; i >= 0; i-- {
+		if strings.HasSuffix(elements[i], ".service") || strings.HasSuffix(elements[i], ".scope") {
+			return elements[i], ""
+		}
+	}
+	return "", ""
+}
+
+func (p *ProcessEnricher) readBootTime() int64 {
+	stat, err := os.ReadFile(filepath.Join(p.ProcPath, "stat"))
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(stat), "\n") {
+		if value, ok := strings.CutPrefix(line, "btime "); ok {
+			return int64(utils.ParseUint(value))
+		}
+	}
+	return 0
+}
+
+func (p *ProcessEnricher) lookupUser(uid string) string {
+	if name, ok := p.users[uid]; ok {
+		return name
+	}
+	name := uid
+	if u, err := user.LookupId(uid); err == nil {
+		name = u.Username
+	}
+	p.users[uid] = name
+	return name
+}
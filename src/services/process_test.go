@@ -0,0 +1,81 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/LanceLRQ/ollama-watchdog/models"
+)
+
+func writeFakeProc(t *testing.T, root string, pid int, utime uint64) {
+	t.Helper()
+	dir := filepath.Join(root, fmt.Sprint(pid))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// 第2列进程名包含空格和括号；第14、15列为 utime、stime，第22列为启动时间（tick）
+	stat := fmt.Sprintf("%d (ollama (runner)) S 1 %d %d 0 -1 4194560 0 0 0 0 %d 0 0 0 20 0 12 0 12345 0 0\n", pid, pid, pid, utime)
+	files := map[string]string{
+		"stat":    stat,
+		"cmdline": "/usr/bin/ollama\x00runner\x00--model\x00/blobs/sha256-abc\x00",
+		"status":  "Name:\tollama\nUid:\t0\t0\t0\t0\nVmRSS:\t  2048000 kB\n",
+		"cgroup":  "0::/system.slice/ollama.service\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestProcessEnricherMultiGPU(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "stat"), []byte("cpu  1 2 3 4\nbtime 1700000000\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	writeFakeProc(t, root, 4242, 100)
+
+	enricher := NewProcessEnricher()
+	enricher.ProcPath = root
+	enricher.Enrich([]models.GPUProcess{{BusId: "a", PID: 4242}})
+
+	time.Sleep(50 * time.Millisecond)
+	writeFakeProc(t, root, 4242, 200)
+	processes := []models.GPUProcess{{BusId: "a", PID: 4242}, {BusId: "b", PID: 4242}}
+	enricher.Enrich(processes)
+
+	first := processes[0]
+	if first.CPUUsed <= 0 {
+		t.Fatalf("CPUUsed = %v, want > 0", first.CPUUsed)
+	}
+	if first.Cmdline != "/usr/bin/ollama runner --model /blobs/sha256-abc" || first.Unit != "ollama.service" ||
+		first.RSS != 2000 || first.PPID != 1 || first.StartTime != 1700000000+123 {
+		t.Errorf("unexpected enrichment: %+v", first)
+	}
+	// 第二块GPU上的同一进程与第一条记录一致
+	second := processes[1]
+	second.BusId = first.BusId
+	if second != first {
+		t.Errorf("second entry differs:\n got %+v\nwant %+v", processes[1], first)
+	}
+}
+
+func TestParseCgroup(t *testing.T) {
+	tests := []struct {
+		content, unit, container string
+	}{
+		{"0::/system.slice/ollama.service\n", "ollama.service", ""},
+		{"0::/system.slice/docker-0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef.scope\n", "", "0123456789ab"},
+		{"12:memory:/docker/0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef\n1:name=systemd:/user.slice/user-1000.slice/session-3.scope\n", "session-3.scope", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		unit, container := ParseCgroup(tt.content)
+		if unit != tt.unit || container != tt.container {
+			t.Errorf("ParseCgroup(%q) = %q, %q, want %q, %q", tt.content, unit, container, tt.unit, tt.container)
+		}
+	}
+}
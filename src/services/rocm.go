@@ -0,0 +1,177 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LanceLRQ/ollama-watchdog/configs"
+	"github.com/LanceLRQ/ollama-watchdog/models"
+	"github.com/LanceLRQ/ollama-watchdog/utils"
+)
+
+func init() {
+	RegisterGPUCollector("rocm", func(cfg *configs.ServerConfigStruct) (GPUCollector, error) {
+		path := cfg.RocmSmiPath
+		if path == "" {
+			path = "rocm-smi"
+		}
+		return &ROCmSMICollector{Path: path}, nil
+	})
+}
+
+// ROCmSMICollector 基于 rocm-smi JSON 输出的 AMD GPU 采集器
+type ROCmSMICollector struct {
+	Path string
+}
+
+func (r *ROCmSMICollector) Collect() (models.NvidiaSMIResponse, error) {
+	cmd := exec.Command(
+		r.Path,
+		"--showid", "--showbus", "--showproductname",
+		"--showuse", "--showmeminfo", "vram", "--showtemp",
+		"--showpower", "--showmaxpower", "--showpids",
+		"--showclocks", "--showfan", "--showperflevel", "--showmemuse",
+		"--json",
+	)
+	output, err := cmd.Output()
+	if err != nil {
+		return models.NvidiaSMIResponse{}, fmt.Errorf("command failed: %v\nOutput: %s", err, output)
+	}
+	return ParseROCmSMIOutput(output)
+}
+
+// ParseROCmSMIOutput 解析 rocm-smi --json 的输出
+//
+// 输出为以 card0、card1... 为键的对象，另有 system 键存放 --showpids 的进程信息，
+// 进程值形如 "python3, 1, 1073741824, 0, 0"（名称、GPU数量、显存字节、SDMA、CU占用）
+func ParseROCmSMIOutput(output []byte) (models.NvidiaSMIResponse, error) {
+	// 部分版本会在JSON前打印警告信息
+	if idx := bytes.IndexByte(output, '{'); idx > 0 {
+		output = output[idx:]
+	}
+	var raw map[string]map[string]any
+	if err := json.Unmarshal(output, &raw); err != nil {
+		return models.NvidiaSMIResponse{}, fmt.Errorf("invalid rocm-smi output: %w", err)
+	}
+
+	cards := make([]string, 0, len(raw))
+	for key := range raw {
+		if strings.HasPrefix(key, "card") {
+			cards = append(cards, key)
+		}
+	}
+	sort.Slice(cards, func(i, j int) bool {
+		return rocmCardIndex(cards[i]) < rocmCardIndex(cards[j])
+	})
+
+	gpuInfos := make([]models.GPUInfo, 0, len(cards))
+	for _, card := range cards {
+		fields := raw[card]
+		info := models.GPUInfo{
+			DeviceId:    rocmField(fields, "Device ID"),
+			BusId:       rocmField(fields, "PCI Bus"),
+			Name:        rocmField(fields, "Card Series", "Card series", "Card Model", "Card model"),
+			MemoryTotal: utils.ParseUint(rocmField(fields, "VRAM Total Memory (B)")) / 1024 / 1024,
+			MemoryUsed:  utils.ParseUint(rocmField(fields, "VRAM Total Used Memory (B)")) / 1024 / 1024,
+			GPUUsed:     utils.ParseUint(rocmField(fields, "GPU use (%)")),
+			Temperature: uint64(utils.ParseFloat(rocmField(fields,
+				"Temperature (Sensor edge) (C)",
+				"Temperature (Sensor junction) (C)",
+			))),
+			PowerUsage: utils.ParseFloat(rocmField(fields,
+				"Average Graphics Package Power (W)",
+				"Current Socket Graphics Package Power (W)",
+			)),
+			PowerLimit: utils.ParseFloat(rocmField(fields, "Max Graphics Package Power (W)")),
+
+			ClockSM:          rocmClock(rocmField(fields, "sclk clock speed:")),
+			ClockMemory:      rocmClock(rocmField(fields, "mclk clock speed:")),
+			FanSpeed:         uint64(utils.ParseFloat(rocmField(fields, "Fan speed (%)"))),
+			PerformanceState: rocmField(fields, "Performance Level"),
+			MemoryBandwidth:  utils.ParseUint(rocmField(fields, "GPU Memory Read/Write Activity (%)", "GPU memory use (%)")),
+		}
+		if info.BusId == "" {
+			info.BusId = card
+		}
+		gpuInfos = append(gpuInfos, info)
+	}
+
+	var gpuProcesses []models.GPUProcess
+	pids := make([]string, 0, len(raw["system"]))
+	for key := range raw["system"] {
+		if strings.HasPrefix(key, "PID") {
+			pids = append(pids, key)
+		}
+	}
+	sort.Strings(pids)
+	for _, key := range pids {
+		value, _ := raw["system"][key].(string)
+		parts := strings.Split(value, ", ")
+		if len(parts) < 3 {
+			continue
+		}
+		// 进程名中可能包含逗号，数值字段固定在末尾，其余部分拼回进程名；
+		// 新版本为GPU数量、显存、SDMA、CU占用四项，旧版本只有GPU数量、显存两项
+		numeric := parts[len(parts)-2:]
+		if len(parts) >= 5 && !slices.ContainsFunc(parts[len(parts)-4:], func(v string) bool {
+			_, err := strconv.ParseUint(strings.TrimSpace(v), 10, 64)
+			return err != nil
+		}) {
+			numeric = parts[len(parts)-4:]
+		}
+		name := strings.TrimSpace(strings.Join(parts[:len(parts)-len(numeric)], ", "))
+		proc := models.GPUProcess{
+			PID:        utils.ParseUint(strings.TrimPrefix(key, "PID")),
+			Name:       name,
+			MemoryUsed: utils.ParseUint(numeric[1]) / 1024 / 1024,
+		}
+		// rocm-smi 不提供进程与GPU的对应关系，仅在单卡时可以确定
+		if len(gpuInfos) == 1 {
+			proc.BusId = gpuInfos[0].BusId
+		}
+		gpuProcesses = append(gpuProcesses, proc)
+	}
+
+	return models.NvidiaSMIResponse{
+		GPUInfo:      gpuInfos,
+		GPUProcesses: gpuProcesses,
+		Timestamp:    time.Now().Unix(),
+	}, nil
+}
+
+// rocmField 按顺序尝试多个字段名（不同版本的 rocm-smi 字段名不同），值为 N/A 时继续尝试下一个
+func rocmField(fields map[string]any, keys ...string) string {
+	for _, key := range keys {
+		if v, ok := fields[key]; ok {
+			switch val := v.(type) {
+			case string:
+				if val = strings.TrimSpace(val); val != "" && val != "N/A" {
+					return val
+				}
+			case float64:
+				return strconv.FormatFloat(val, 'f', -1, 64)
+			}
+		}
+	}
+	return ""
+}
+
+// rocmClock 解析形如 "(1800Mhz)" 的时钟频率
+func rocmClock(value string) uint64 {
+	return utils.ParseUint(strings.Trim(value, "()"))
+}
+
+func rocmCardIndex(card string) int {
+	n, err := strconv.Atoi(strings.TrimPrefix(card, "card"))
+	if err != nil {
+		return -1
+	}
+	return n
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/LanceLRQ/ollama-watchdog/models"
+)
+
+func TestParseROCmSMIOutput(t *testing.T) {
+	tests := []struct {
+		fixture   string
+		gpus      []models.GPUInfo
+		processes []models.GPUProcess
+	}{
+		{
+			fixture: "rocm5_single.json",
+			gpus: []models.GPUInfo{{
+				DeviceId: "0x73bf", BusId: "0000:03:00.0", Name: "Navi 21 [Radeon RX 6800/6800 XT / 6900 XT]",
+				MemoryTotal: 16368, MemoryUsed: 8192, GPUUsed: 98, Temperature: 45,
+				PowerUsage: 180, PowerLimit: 272, ClockSM: 2105, ClockMemory: 1000,
+				FanSpeed: 25, PerformanceState: "auto", MemoryBandwidth: 41,
+			}},
+			processes: []models.GPUProcess{
+				{BusId: "0000:03:00.0", PID: 4242, Name: "ollama_llama_server", MemoryUsed: 8000},
+				{BusId: "0000:03:00.0", PID: 977, Name: "Xorg", MemoryUsed: 50},
+			},
+		},
+		{
+			fixture: "rocm6_multi.json",
+			gpus: []models.GPUInfo{
+				{
+					DeviceId: "0x740f", BusId: "0000:c1:00.0", Name: "AMD Instinct MI210",
+					MemoryTotal: 65520, MemoryUsed: 32768, GPUUsed: 100, Temperature: 61,
+					PowerUsage: 310, PowerLimit: 500, ClockSM: 1700, ClockMemory: 1600,
+					PerformanceState: "auto", MemoryBandwidth: 63,
+				},
+				{
+					DeviceId: "0x740f", BusId: "0000:c2:00.0", Name: "AMD Instinct MI210",
+					MemoryTotal: 65520, MemoryUsed: 10, GPUUsed: 0, Temperature: 40,
+					PowerUsage: 42, PowerLimit: 500, ClockSM: 800, ClockMemory: 1600,
+					PerformanceState: "auto",
+				},
+			},
+			// 多卡时无法确定进程所在GPU；旧格式只有三项
+			processes: []models.GPUProcess{
+				{PID: 31337, Name: "python3 train.py --tag a,b", MemoryUsed: 16384},
+				{PID: 512, Name: "ollama", MemoryUsed: 1024},
+				{PID: 600, Name: "python3 serve.py --ports 8000,8001", MemoryUsed: 2048},
+				{PID: 601, Name: "sh -c a, b, c", MemoryUsed: 4},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			output, err := os.ReadFile(filepath.Join("testdata", "rocm", tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := ParseROCmSMIOutput(output)
+			if err != nil {
+				t.Fatalf("ParseROCmSMIOutput: %v", err)
+			}
+			if len(resp.GPUInfo) != len(tt.gpus) {
+				t.Fatalf("got %d GPUs, want %d", len(resp.GPUInfo), len(tt.gpus))
+			}
+			for i, want := range tt.gpus {
+				if got := resp.GPUInfo[i]; got.DeviceId != want.DeviceId || got.BusId != want.BusId || got.Name != want.Name ||
+					got.MemoryTotal != want.MemoryTotal || got.MemoryUsed != want.MemoryUsed || got.GPUUsed != want.GPUUsed ||
+					got.Temperature != want.Temperature || got.PowerUsage != want.PowerUsage || got.PowerLimit != want.PowerLimit ||
+					got.ClockSM != want.ClockSM || got.ClockMemory != want.ClockMemory || got.FanSpeed != want.FanSpeed ||
+					got.PerformanceState != want.PerformanceState || got.MemoryBandwidth != want.MemoryBandwidth {
+					t.Errorf("GPU %d:\n got %+v\nwant %+v", i, got, want)
+				}
+			}
+			if len(resp.GPUProcesses) != len(tt.processes) {
+				t.Fatalf("got %d processes, want %d: %+v", len(resp.GPUProcesses), len(tt.processes), resp.GPUProcesses)
+			}
+			// 进程按 PID 键名字符串排序
+			for _, want := range tt.processes {
+				found := false
+				for _, got := range resp.GPUProcesses {
+					if got == want {
+						found = true
+					}
+				}
+				if !found {
+					t.Errorf("process %+v not found in %+v", want, resp.GPUProcesses)
+				}
+			}
+		})
+	}
+}
+
+func TestParseROCmSMIOutputInvalid(t *testing.T) {
+	if _, err := ParseROCmSMIOutput([]byte("rocm-smi: command failed")); err == nil {
+		t.Error("expected error for non-JSON output")
+	}
+}
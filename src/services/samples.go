@@ -0,0 +1,135 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// 键中的毫秒时间戳固定为13位，补零后字节序与时间顺序一致；
+// 早期版本以秒为单位写入的10位键与之混排，读取时跳过，随保留期自然过期
+const sampleKeyDigits = 13
+
+func sampleKey(prefix string, timestampMs int64) []byte {
+	return fmt.Appendf(nil, "%s:%0*d", prefix, sampleKeyDigits, timestampMs)
+}
+
+// saveSample 以 "<prefix>:<毫秒时间戳>" 为键写入一条采样数据，retention 为0时永久保留
+func saveSample(db *badger.DB, prefix string, timestampMs int64, retention time.Duration, value any) error {
+	jsonData, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("JSON marshal error: %w", err)
+	}
+	return db.Update(func(txn *badger.Txn) error {
+		e := badger.NewEntry(sampleKey(prefix, timestampMs), jsonData)
+		if retention > 0 {
+			e = e.WithTTL(retention)
+		}
+		if err := txn.SetEntry(e); err != nil {
+			return fmt.Errorf("failed to record %s sample: %w", prefix, err)
+		}
+		return nil
+	})
+}
+
+// LoadSamples 读取 prefix 下从 startMs（毫秒时间戳）开始的所有采样数据
+func LoadSamples[T any](db *badger.DB, prefix string, startMs int64) ([]T, error) {
+	return LoadSampleRange[T](db, prefix, startMs, math.MaxInt64)
+}
+
+// LoadSampleRange 读取 prefix 下时间在 [startMs, endMs) 内的采样数据
+func LoadSampleRange[T any](db *badger.DB, prefix string, startMs int64, endMs int64) ([]T, error) {
+	list := make([]T, 0)
+	err := db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		keyPrefix := []byte(prefix + ":")
+		for it.Seek(sampleKey(prefix, startMs)); it.ValidForPrefix(keyPrefix); it.Next() {
+			ts, ok := sampleKeyTime(it.Item().Key(), prefix)
+			if !ok {
+				continue
+			}
+			if ts >= endMs {
+				break
+			}
+			v, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			var item T
+			if err := json.Unmarshal(v, &item); err != nil {
+				return err
+			}
+			list = append(list, item)
+		}
+		return nil
+	})
+	return list, err
+}
+
+// firstSampleTime 获取 prefix 下不早于 startMs 的第一条采样的时间
+func firstSampleTime(db *badger.DB, prefix string, startMs int64) (int64, bool) {
+	var ts int64
+	found := false
+	db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(sampleKey(prefix, startMs)); it.ValidForPrefix([]byte(prefix + ":")); it.Next() {
+			if ts, found = sampleKeyTime(it.Item().Key(), prefix); found {
+				break
+			}
+		}
+		return nil
+	})
+	return ts, found
+}
+
+// lastSampleTime 获取 prefix 下最后一条采样的时间
+func lastSampleTime(db *badger.DB, prefix string) (int64, bool) {
+	var ts int64
+	found := false
+	db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Reverse = true
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		// 反向迭代时 Seek 定位到不大于该键的最后一个键
+		for it.Seek([]byte(prefix + ":\xff")); it.ValidForPrefix([]byte(prefix + ":")); it.Next() {
+			if ts, found = sampleKeyTime(it.Item().Key(), prefix); found {
+				break
+			}
+		}
+		return nil
+	})
+	return ts, found
+}
+
+// sampleKeyTime 解析 "<prefix>:<毫秒时间戳>" 中的时间戳，旧格式的键返回 false
+func sampleKeyTime(key []byte, prefix string) (int64, bool) {
+	digits := strings.TrimPrefix(string(key), prefix+":")
+	if len(digits) != sampleKeyDigits {
+		return 0, false
+	}
+	ts, err := strconv.ParseInt(digits, 10, 64)
+	return ts, err == nil
+}
+
+// HistoryGCWatcher 定期回收 badger 中已过期数据占用的 value log 空间
+func HistoryGCWatcher(db *badger.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		// 每次尽量多回收几个文件，直到没有可回收的
+		for db.RunValueLogGC(0.5) == nil {
+		}
+	}
+}
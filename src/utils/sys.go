@@ -4,8 +4,6 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
-
-	"github.com/LanceLRQ/ollama-watchdog/configs"
 )
 
 func TerminateProcess(pid int) error {
@@ -40,19 +38,3 @@ func RebootSystem() error {
 	cmd := exec.Command("reboot")
 	return cmd.Run()
 }
-
-func TerminateOllamaProcess(cfg *configs.ServerConfigStruct, modelName string, server string) error {
-	cmd := exec.Command("ollama", "stop", modelName)
-	// 1. 获取当前环境变量（可选）
-	env := os.Environ()
-	// 2. 添加自定义环境变量（例如 OLLAMA_HOST）
-	if server == "" {
-		env = append(env, fmt.Sprintf("OLLAMA_HOST=%s", cfg.OllamaListen))
-	} else {
-		env = append(env, fmt.Sprintf("OLLAMA_HOST=%s", server))
-	}
-	// 3. 设置 cmd 的环境变量
-	cmd.Env = env
-	// 4. 运行命令
-	return cmd.Run()
-}
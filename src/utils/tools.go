@@ -18,3 +18,40 @@ func ParseFloat(s string) float64 {
 	fmt.Sscanf(strings.TrimSpace(s), "%f", &f)
 	return f
 }
+
+// 辅助函数：按空白拆分命令行，支持单双引号包裹含空格的参数
+func SplitCommandLine(s string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	var quote rune
+	inArg := false
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in command: %s", s)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}